@@ -0,0 +1,316 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package audit provides a tamper-evident writer for audit logs.
+//
+// The Writer ends every line with the SHA-256 hash of the previous line's
+// hash and the line itself, so that modifying, inserting or deleting a line
+// breaks the chain of the following ones:
+//
+//	INFO user=gopher action=login sha256=5d41402abc4b2a76b9719d911017c592...
+//
+// It also writes signed checkpoints, which are lines of the chain whose
+// content is
+//
+//	#checkpoint lines=1000 sig=<base64>
+//
+// where the signature, by an HMAC or ed25519 key, covers the number of
+// lines before the checkpoint and the hash of the last one. Ed25519 keys
+// need Go 1.13 or later. Without the key,
+// a forger can rewrite the whole chain but not the checkpoints. Lines after
+// the last checkpoint, which Verify reports as not signed, may have been
+// appended or removed. Records starting with "#checkpoint " are written
+// with a backslash before, so that they cannot forge checkpoints.
+//
+//	w := audit.NewWriter(f, &audit.Options{Signer: audit.HMAC(key), Every: 100})
+//	logger := log.New(w, log.LstdFlags|log.LUTC|log.Lsanitize, log.LevelAll)
+//	defer w.Close()
+//
+// Verify walks a file and reports the first broken link.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Markers of the audit lines.
+const (
+	HashMarker       = " sha256="
+	CheckpointPrefix = "#checkpoint "
+)
+
+// hashLen is the length of a hex encoded hash.
+const hashLen = 2 * sha256.Size
+
+// A Signer signs checkpoints.
+type Signer interface {
+	Sign(msg []byte) ([]byte, error)
+}
+
+// A Verifier checks the signatures of checkpoints.
+type Verifier interface {
+	Verify(msg, sig []byte) bool
+}
+
+// HMAC is an HMAC-SHA256 key; it is both a Signer and a Verifier.
+type HMAC []byte
+
+// Sign implements Signer.
+func (k HMAC) Sign(msg []byte) ([]byte, error) {
+	m := hmac.New(sha256.New, k)
+	m.Write(msg)
+	return m.Sum(nil), nil
+}
+
+// Verify implements Verifier.
+func (k HMAC) Verify(msg, sig []byte) bool {
+	want, _ := k.Sign(msg)
+	return hmac.Equal(want, sig)
+}
+
+// Options configure a Writer.
+type Options struct {
+	Signer Signer // if nil, no checkpoints are written
+	Every  int    // lines between checkpoints; default 1000
+
+	// Lines and Last resume the chain of an existing file, as returned
+	// by Verify, so that a Writer can append to it.
+	Lines int
+	Last  [sha256.Size]byte
+}
+
+// A Writer writes hash-chained lines to an underlying writer. Bytes are
+// buffered until the end of their line. A line whose write fails is
+// dropped, and the chain goes on from the line before it. A Writer is safe for concurrent
+// use; each complete line is written by a single call to Write.
+type Writer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	signer Signer
+	every  int
+	lines  int               // lines written
+	since  int               // lines written since the last checkpoint
+	last   [sha256.Size]byte // hash of the last line
+	buf    []byte            // incomplete line
+	out    []byte            // line being written
+	closed bool
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer, opts *Options) *Writer {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Every <= 0 {
+		o.Every = 1000
+	}
+	return &Writer{w: w, signer: o.Signer, every: o.Every, lines: o.Lines, last: o.Last}
+}
+
+// chain returns the hash of line following the hash prev.
+func chain(prev [sha256.Size]byte, line []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(prev[:])
+	h.Write(line)
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// checkpointMessage returns the signed content of a checkpoint.
+func checkpointMessage(lines int, last [sha256.Size]byte) []byte {
+	return []byte("lines=" + strconv.Itoa(lines) + HashMarker + hex.EncodeToString(last[:]))
+}
+
+// Write implements io.Writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, errors.New("audit: write to closed Writer")
+	}
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		err := w.writeRecord(w.buf[:i])
+		w.buf = w.buf[i+1:]
+		if err != nil {
+			return 0, err
+		}
+		if w.since >= w.every && w.signer != nil {
+			if err := w.checkpoint(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if len(w.buf) == 0 {
+		w.buf = w.buf[:0:0] // release the memory of long writes.
+	}
+	return len(p), nil
+}
+
+// writeRecord writes a line written to w, escaped if it starts as a
+// checkpoint does. w.mu must be held.
+func (w *Writer) writeRecord(line []byte) error {
+	if bytes.HasPrefix(line, []byte(CheckpointPrefix)) {
+		line = append([]byte{'\\'}, line...)
+	}
+	return w.writeLine(line)
+}
+
+// writeLine chains line and writes it. The chain advances only if the
+// write succeeds, so that a failed line is skipped rather than breaking
+// the chain. w.mu must be held.
+func (w *Writer) writeLine(line []byte) error {
+	last := chain(w.last, line)
+	w.out = append(w.out[:0], line...)
+	w.out = append(w.out, HashMarker...)
+	w.out = append(w.out, hex.EncodeToString(last[:])...)
+	w.out = append(w.out, '\n')
+	if _, err := w.w.Write(w.out); err != nil {
+		return err
+	}
+	w.last = last
+	w.lines++
+	w.since++
+	return nil
+}
+
+// checkpoint writes a signed checkpoint. w.mu must be held.
+func (w *Writer) checkpoint() error {
+	sig, err := w.signer.Sign(checkpointMessage(w.lines, w.last))
+	if err != nil {
+		return err
+	}
+	err = w.writeLine([]byte(CheckpointPrefix + "lines=" + strconv.Itoa(w.lines) + " sig=" + base64.StdEncoding.EncodeToString(sig)))
+	if err == nil {
+		w.since = 0
+	}
+	return err
+}
+
+// Checkpoint writes a signed checkpoint now.
+func (w *Writer) Checkpoint() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.signer == nil {
+		return errors.New("audit: no Signer")
+	}
+	return w.checkpoint()
+}
+
+// Close writes the incomplete line, if any, and a final checkpoint if a
+// line was written since the last one. It does not close the underlying
+// writer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if len(w.buf) > 0 {
+		err := w.writeRecord(w.buf)
+		w.buf = nil
+		if err != nil {
+			return err
+		}
+	}
+	if w.signer != nil && w.since > 0 {
+		return w.checkpoint()
+	}
+	return nil
+}
+
+// A BrokenError reports the first line of a file that does not verify.
+type BrokenError struct {
+	Line   int // 1-based
+	Reason string
+}
+
+func (e *BrokenError) Error() string {
+	return "audit: line " + strconv.Itoa(e.Line) + ": " + e.Reason
+}
+
+// A Result describes a verified file.
+type Result struct {
+	Lines       int               // lines of the chain, checkpoints included
+	Checkpoints int               // verified checkpoints
+	Signed      int               // lines covered by the last verified checkpoint
+	Last        [sha256.Size]byte // hash of the last line
+}
+
+// Verify walks the lines of r, checking their hash chain and, if v is not
+// nil, the signatures of the checkpoints. It returns a *BrokenError for the
+// first line that does not verify, along with the result up to the line
+// before it. The Lines and Last of the result resume the chain in Options.
+func Verify(r io.Reader, v Verifier) (Result, error) {
+	var res Result
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) == 0 && err == io.EOF {
+			return res, nil
+		}
+		if err != nil && err != io.EOF {
+			return res, err
+		}
+		n := res.Lines + 1
+		if line[len(line)-1] != '\n' {
+			return res, &BrokenError{n, "incomplete line"}
+		}
+		line = line[:len(line)-1]
+		i := len(line) - len(HashMarker) - hashLen
+		if i < 0 || string(line[i:i+len(HashMarker)]) != HashMarker {
+			return res, &BrokenError{n, "missing hash"}
+		}
+		content := line[:i]
+		sum := chain(res.Last, content)
+		if hex.EncodeToString(sum[:]) != string(line[i+len(HashMarker):]) {
+			return res, &BrokenError{n, "hash mismatch"}
+		}
+		if bytes.HasPrefix(content, []byte(CheckpointPrefix)) && v != nil {
+			if reason := verifyCheckpoint(content, res, v); reason != "" {
+				return res, &BrokenError{n, reason}
+			}
+			res.Checkpoints++
+			res.Signed = n - 1
+		}
+		res.Lines = n
+		res.Last = sum
+	}
+}
+
+// verifyCheckpoint checks the checkpoint content following the lines of
+// res, and returns why it fails, or "".
+func verifyCheckpoint(content []byte, res Result, v Verifier) string {
+	var lines int
+	var sig string
+	_, err := fmt.Sscanf(string(content[len(CheckpointPrefix):]), "lines=%d sig=%s", &lines, &sig)
+	if err != nil {
+		return "malformed checkpoint"
+	}
+	if lines != res.Lines {
+		return "checkpoint of " + strconv.Itoa(lines) + " lines after " + strconv.Itoa(res.Lines)
+	}
+	b, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil || !v.Verify(checkpointMessage(lines, res.Last), b) {
+		return "bad checkpoint signature"
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-gem/log"
+)
+
+var key = HMAC("secret")
+
+// write logs n records through a Writer and returns the file.
+func write(t *testing.T, n int, opts *Options) string {
+	var b bytes.Buffer
+	w := NewWriter(&b, opts)
+	l := log.New(w, 0, log.LevelAll)
+	for i := 0; i < n; i++ {
+		l.Infof("record %d", i)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestVerify(t *testing.T) {
+	file := write(t, 5, &Options{Signer: key, Every: 2})
+	lines := strings.Split(strings.TrimSuffix(file, "\n"), "\n")
+	// 5 records, a checkpoint after the 2nd and 4th, and a final one.
+	if len(lines) != 8 || !strings.HasPrefix(lines[2], CheckpointPrefix+"lines=2 sig=") {
+		t.Fatalf("unexpected file:\n%s", file)
+	}
+	res, err := Verify(strings.NewReader(file), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Lines != 8 || res.Checkpoints != 3 || res.Signed != 7 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if _, err := Verify(strings.NewReader(file), HMAC("other")); err == nil || err.(*BrokenError).Line != 3 {
+		t.Errorf("Verify with the wrong key = %v", err)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	file := write(t, 4, &Options{Signer: key, Every: 10})
+	lines := strings.SplitAfter(file, "\n")
+	tests := []struct {
+		name   string
+		file   string
+		line   int
+		reason string
+	}{
+		{"modified", strings.Replace(file, "record 1", "record 9", 1), 2, "hash mismatch"},
+		{"deleted", strings.Join(append(lines[:1:1], lines[2:]...), ""), 2, "hash mismatch"},
+		{"truncated", file[:len(file)-10], 5, "incomplete line"},
+		{"unhashed", "INFO forged\n" + file, 1, "missing hash"},
+	}
+	for _, tt := range tests {
+		_, err := Verify(strings.NewReader(tt.file), key)
+		e, ok := err.(*BrokenError)
+		if !ok || e.Line != tt.line || e.Reason != tt.reason {
+			t.Errorf("%s: Verify = %v, want line %d: %s", tt.name, err, tt.line, tt.reason)
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	var b bytes.Buffer
+	w := NewWriter(&b, &Options{Signer: key})
+	w.Write([]byte("first\nsec"))
+	w.Write([]byte("ond\n"))
+	w.Close()
+	res, err := Verify(bytes.NewReader(b.Bytes()), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = NewWriter(&b, &Options{Signer: key, Lines: res.Lines, Last: res.Last})
+	w.Write([]byte("third\n"))
+	w.Close()
+	res, err = Verify(bytes.NewReader(b.Bytes()), key)
+	if err != nil || res.Lines != 5 || res.Checkpoints != 2 {
+		t.Errorf("Verify = %+v, %v", res, err)
+	}
+}
+
+// failOnce fails the write of the given index.
+type failOnce struct {
+	bytes.Buffer
+	fail, n int
+}
+
+func (w *failOnce) Write(p []byte) (int, error) {
+	w.n++
+	if w.n == w.fail {
+		return 0, errors.New("transient")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestWriteFailure(t *testing.T) {
+	w := &failOnce{fail: 2}
+	aw := NewWriter(w, &Options{Signer: key, Every: 2})
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n"} {
+		_, err := aw.Write([]byte(line))
+		if (err != nil) != (line == "two\n") {
+			t.Errorf("Write(%q) = %v", line, err)
+		}
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Verify(strings.NewReader(w.String()), key)
+	if err != nil {
+		t.Fatalf("%v in:\n%s", err, w.String())
+	}
+	if res.Lines != 5 || res.Checkpoints != 2 || strings.Contains(w.String(), "two") {
+		t.Errorf("unexpected result %+v of:\n%s", res, w.String())
+	}
+}
+
+func TestForgedCheckpoint(t *testing.T) {
+	var b bytes.Buffer
+	w := NewWriter(&b, &Options{Signer: key})
+	l := log.New(w, 0, log.LevelAll)
+	l.Print(CheckpointPrefix + "lines=0 sig=AAAA")
+	l.Print("after")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Verify(strings.NewReader(b.String()), key)
+	if err != nil {
+		t.Fatalf("%v in:\n%s", err, b.String())
+	}
+	if res.Lines != 3 || res.Checkpoints != 1 || !strings.HasPrefix(b.String(), `\`+CheckpointPrefix) {
+		t.Errorf("unexpected result %+v of:\n%s", res, b.String())
+	}
+}
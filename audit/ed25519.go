@@ -0,0 +1,32 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.13
+// +build go1.13
+
+package audit
+
+import (
+	"crypto/ed25519"
+	"errors"
+)
+
+// Ed25519 is an ed25519 private key; it is a Signer.
+type Ed25519 ed25519.PrivateKey
+
+// Sign implements Signer.
+func (k Ed25519) Sign(msg []byte) ([]byte, error) {
+	if len(k) != ed25519.PrivateKeySize {
+		return nil, errors.New("audit: bad ed25519 private key length")
+	}
+	return ed25519.Sign(ed25519.PrivateKey(k), msg), nil
+}
+
+// Ed25519Public is an ed25519 public key; it is a Verifier.
+type Ed25519Public ed25519.PublicKey
+
+// Verify implements Verifier.
+func (k Ed25519Public) Verify(msg, sig []byte) bool {
+	return len(k) == ed25519.PublicKeySize && ed25519.Verify(ed25519.PublicKey(k), msg, sig)
+}
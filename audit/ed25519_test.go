@@ -0,0 +1,29 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.13
+// +build go1.13
+
+package audit
+
+import (
+	"crypto/ed25519"
+	"strings"
+	"testing"
+)
+
+func TestEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := write(t, 3, &Options{Signer: Ed25519(priv)})
+	if res, err := Verify(strings.NewReader(file), Ed25519Public(pub)); err != nil || res.Checkpoints != 1 {
+		t.Errorf("Verify = %+v, %v", res, err)
+	}
+	other, _, _ := ed25519.GenerateKey(nil)
+	if _, err := Verify(strings.NewReader(file), Ed25519Public(other)); err == nil {
+		t.Error("expected a bad signature")
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// A frame is the caller information of a call site.
+type frame struct {
+	pc       uintptr
+	file     string
+	line     int
+	function string // fully qualified: github.com/a/b.(*Server).handle
+	relFile  string // relativeFile(file), if hasRel
+	hasRel   bool
+}
+
+// frames caches the frames of call sites by program counter, so that the
+// symbol tables and the file system are searched once per call site. Call
+// sites are finite, so the cache is never pruned.
+var frames struct {
+	sync.RWMutex
+	m map[uintptr]*frame
+}
+
+func loadFrame(pc uintptr) *frame {
+	frames.RLock()
+	defer frames.RUnlock()
+	return frames.m[pc]
+}
+
+func storeFrame(pc uintptr, f *frame) {
+	frames.Lock()
+	defer frames.Unlock()
+	if frames.m == nil {
+		frames.m = make(map[uintptr]*frame)
+	}
+	frames.m[pc] = f
+}
+
+// callerFrame returns the frame that runtime.Caller(calldepth) returns
+// for its caller, or nil if there is none. If rel is set, the relative
+// file name of the frame is computed too.
+func callerFrame(calldepth int, rel bool) *frame {
+	var pcs [1]uintptr
+	if runtime.Callers(calldepth+2, pcs[:]) == 0 { // skip runtime.Callers and callerFrame.
+		return nil
+	}
+	if f := loadFrame(pcs[0]); f != nil {
+		if !rel || f.hasRel {
+			return f
+		}
+		rf := *f
+		rf.relFile, rf.hasRel = relativeFile(f.file), true
+		storeFrame(pcs[0], &rf)
+		return &rf
+	}
+	fr, _ := runtime.CallersFrames(pcs[:]).Next()
+	if fr.PC == 0 {
+		return nil
+	}
+	f := &frame{pc: fr.PC, file: fr.File, line: fr.Line, function: fr.Function}
+	if rel {
+		f.relFile, f.hasRel = relativeFile(f.file), true
+	}
+	storeFrame(pcs[0], f)
+	return f
+}
+
+// splitFuncName splits the fully qualified name of a function into the
+// path of its package and its name within the package:
+// "github.com/a/b.(*Server).handle" into "github.com/a/b" and
+// "(*Server).handle".
+func splitFuncName(name string) (pkg, fn string) {
+	i := strings.LastIndexByte(name, '/')
+	j := strings.IndexByte(name[i+1:], '.')
+	if j < 0 {
+		return "", name
+	}
+	pkg, fn = name[:i+1+j], name[i+2+j:]
+	// the linker escapes the dots of the last element of package paths.
+	return strings.Replace(pkg, "%2e", ".", -1), fn
+}
+
+// funcHeader returns the caller function written in headers with the
+// given flags.
+func funcHeader(flag int, function string) string {
+	if function == "" {
+		return "???"
+	}
+	pkg, fn := splitFuncName(function)
+	switch flag & (Lfunc | Lpackage) {
+	case Lfunc:
+		return fn
+	case Lpackage:
+		if pkg == "" {
+			return "???"
+		}
+		return pkg
+	}
+	if pkg == "" {
+		return fn
+	}
+	return pkg + "." + fn
+}
+
+// moduleRoots caches, per directory, the root directory and path of the
+// module containing it. A directory outside of any module maps to a
+// zero moduleRoot.
+var moduleRoots struct {
+	sync.Mutex
+	m map[string]moduleRoot
+}
+
+type moduleRoot struct {
+	dir  string
+	path string
+}
+
+// relativeFile returns file relative to the root of its module, prefixed
+// with the module path, or else relative to the src directory of the
+// GOPATH entry containing it. If neither applies, file is returned as is.
+func relativeFile(file string) string {
+	if root := findModuleRoot(path.Dir(file)); root.dir != "" {
+		rel := strings.TrimPrefix(file[len(root.dir):], "/")
+		if root.path == "" || root.path == "std" {
+			return rel
+		}
+		return root.path + "/" + rel
+	}
+	for _, gopath := range gopaths() {
+		src := filepath.ToSlash(gopath) + "/src/"
+		if strings.HasPrefix(file, src) {
+			return file[len(src):]
+		}
+	}
+	return file
+}
+
+func findModuleRoot(dir string) moduleRoot {
+	moduleRoots.Lock()
+	root, ok := moduleRoots.m[dir]
+	moduleRoots.Unlock()
+	if ok {
+		return root
+	}
+	if mod, err := os.Open(filepath.FromSlash(dir + "/go.mod")); err == nil {
+		root.dir = dir
+		root.path = modulePath(mod)
+		mod.Close()
+	} else if parent := path.Dir(dir); parent != dir && parent != "." {
+		root = findModuleRoot(parent)
+	}
+	moduleRoots.Lock()
+	if moduleRoots.m == nil {
+		moduleRoots.m = make(map[string]moduleRoot)
+	}
+	moduleRoots.m[dir] = root
+	moduleRoots.Unlock()
+	return root
+}
+
+// modulePath returns the path declared by the module directive of a
+// go.mod file, or "" if there is none.
+func modulePath(mod *os.File) string {
+	scanner := bufio.NewScanner(mod)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "module ") || strings.HasPrefix(line, "module\t") {
+			p := strings.TrimSpace(line[len("module"):])
+			return strings.Trim(p, "\"`")
+		}
+	}
+	return ""
+}
+
+func gopaths() []string {
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		if home := os.Getenv("HOME"); home != "" {
+			return []string{filepath.Join(home, "go")}
+		}
+		return nil
+	}
+	return filepath.SplitList(gopath)
+}
@@ -0,0 +1,145 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRelativeFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mod := filepath.Join(dir, "mod")
+	if err := os.MkdirAll(filepath.Join(mod, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(mod, "go.mod"), []byte("module example.com/m\n\ngo 1.5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	os.Setenv("GOPATH", filepath.Join(dir, "gopath"))
+
+	slash := filepath.ToSlash(dir)
+	tests := []struct {
+		file, want string
+	}{
+		{slash + "/mod/sub/a.go", "example.com/m/sub/a.go"},
+		{slash + "/mod/b.go", "example.com/m/b.go"},
+		{slash + "/gopath/src/github.com/x/y/c.go", "github.com/x/y/c.go"},
+		{slash + "/elsewhere/d.go", slash + "/elsewhere/d.go"},
+	}
+	for _, test := range tests {
+		if got := relativeFile(test.file); got != test.want {
+			t.Errorf("relativeFile(%q) = %q; want %q", test.file, got, test.want)
+		}
+	}
+}
+
+type callerT struct{}
+
+func (*callerT) log(l *Logger) {
+	l.Info("hello")
+}
+
+func TestFuncFlags(t *testing.T) {
+	pkg := reflect.TypeOf(callerT{}).PkgPath()
+	tests := []struct {
+		flag int
+		want string
+	}{
+		{Lfunc, "INFO (*callerT).log: hello\n"},
+		{Lpackage, "INFO " + pkg + ": hello\n"},
+		{Lfunc | Lpackage, "INFO " + pkg + ".(*callerT).log: hello\n"},
+		{Lshortfile | Lfunc, "INFO caller_test.go:" + strconv.Itoa(callerTLine) + ": (*callerT).log: hello\n"},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		l := New(&b, test.flag, LevelAll)
+		new(callerT).log(l)
+		if b.String() != test.want {
+			t.Errorf("flag %#x: got %q, want %q", test.flag, b.String(), test.want)
+			continue
+		}
+		r, err := Parse(b.String(), test.flag)
+		if err != nil || r.Message != "hello" || !strings.Contains(pkg+".(*callerT).log", r.Function) {
+			t.Errorf("Parse(%q, %#x) = %+v, %v", b.String(), test.flag, r, err)
+		}
+	}
+
+	var b bytes.Buffer
+	l := New(&b, Lfunc, LevelAll)
+	l.SetHandler(HandlerFunc(func(r Record) error {
+		if !strings.HasPrefix(r.Function, pkg+".TestFuncFlags.func") || r.PC == 0 || r.File != "" {
+			t.Errorf("record of %s at %#x in %q", r.Function, r.PC, r.File)
+		}
+		return nil
+	}))
+	func() { l.Info("closure") }()
+}
+
+// callerTLine is the line of the call in callerT.log.
+const callerTLine = 54
+
+func TestSplitFuncName(t *testing.T) {
+	tests := []struct {
+		name, pkg, fn string
+	}{
+		{"github.com/a/b.(*Server).handle", "github.com/a/b", "(*Server).handle"},
+		{"github.com/a/b.F.func1", "github.com/a/b", "F.func1"},
+		{"gopkg.in/yaml%2ev2.Marshal", "gopkg.in/yaml.v2", "Marshal"},
+		{"main.main", "main", "main"},
+		{"nodot", "", "nodot"},
+	}
+	for _, test := range tests {
+		if pkg, fn := splitFuncName(test.name); pkg != test.pkg || fn != test.fn {
+			t.Errorf("splitFuncName(%q) = %q, %q; want %q, %q", test.name, pkg, fn, test.pkg, test.fn)
+		}
+	}
+}
+
+func callerSite(rel bool) *frame {
+	return callerFrame(0, rel)
+}
+
+func TestCallerFrameCache(t *testing.T) {
+	var fs []*frame
+	for _, rel := range []bool{false, false, true, true} {
+		fs = append(fs, callerSite(rel))
+	}
+	if fs[1] != fs[0] {
+		t.Error("frame of a call site not cached")
+	}
+	if fs[3] != fs[2] {
+		t.Error("frame with relative file not cached")
+	}
+	f := fs[3]
+	if !f.hasRel || f.relFile != relativeFile(f.file) || !strings.HasSuffix(f.function, ".callerSite") {
+		t.Errorf("frame = %+v", f)
+	}
+	if _, file, _, _ := runtime.Caller(0); f.file != file {
+		t.Errorf("file = %q, want %q", f.file, file)
+	}
+}
+
+func BenchmarkPrintlnFunc(b *testing.B) {
+	const testString = "test"
+	var buf bytes.Buffer
+	l := New(&buf, Lshortfile|Lfunc, LevelAll)
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		l.Println(testString)
+	}
+}
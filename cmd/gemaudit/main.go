@@ -0,0 +1,98 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.13
+// +build go1.13
+
+// Gemaudit verifies the hash chain and the checkpoints of audit logs
+// written by package github.com/go-gem/log/audit.
+//
+// Usage:
+//
+//	gemaudit [-hmac keyfile | -ed25519 pubkeyfile] file...
+//
+// Key files hold the HMAC key or the ed25519 public key, hex encoded.
+// Without a key only the hash chain is verified. Gemaudit prints the
+// first broken line of each file and exits with status 1 if any is found.
+// Gemaudit requires Go 1.13, for crypto/ed25519.
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/go-gem/log/audit"
+)
+
+var (
+	hmacFile    = flag.String("hmac", "", "file of the hex encoded HMAC key")
+	ed25519File = flag.String("ed25519", "", "file of the hex encoded ed25519 public key")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gemaudit [-hmac keyfile | -ed25519 pubkeyfile] file...\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func readKey(name string) []byte {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gemaudit: %v\n", err)
+		os.Exit(2)
+	}
+	key, err := hex.DecodeString(string(bytes.TrimSpace(b)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gemaudit: %s: %v\n", name, err)
+		os.Exit(2)
+	}
+	return key
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 || *hmacFile != "" && *ed25519File != "" {
+		usage()
+	}
+	var v audit.Verifier
+	switch {
+	case *hmacFile != "":
+		v = audit.HMAC(readKey(*hmacFile))
+	case *ed25519File != "":
+		key := readKey(*ed25519File)
+		if len(key) != ed25519.PublicKeySize {
+			fmt.Fprintf(os.Stderr, "gemaudit: %s: not an ed25519 public key\n", *ed25519File)
+			os.Exit(2)
+		}
+		v = audit.Ed25519Public(key)
+	}
+	status := 0
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gemaudit: %v\n", err)
+			status = 1
+			continue
+		}
+		res, err := audit.Verify(f, v)
+		f.Close()
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			status = 1
+			continue
+		}
+		if v == nil {
+			fmt.Printf("%s: ok, %d lines\n", name, res.Lines)
+		} else {
+			fmt.Printf("%s: ok, %d lines, %d signed by %d checkpoints\n", name, res.Lines, res.Signed, res.Checkpoints)
+		}
+	}
+	os.Exit(status)
+}
@@ -0,0 +1,80 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Gemdecrypt decrypts logs written by package
+// github.com/go-gem/log/encrypt to standard output.
+//
+// Usage:
+//
+//	gemdecrypt -key keyfile [file...]
+//
+// The key file holds the AES key wrapping the data keys, hex encoded.
+// Without files, gemdecrypt reads standard input. The data of truncated
+// files, as left by a crash, is recovered up to their last complete chunk
+// with a warning.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/go-gem/log/encrypt"
+)
+
+var keyFile = flag.String("key", "", "file of the hex encoded AES key")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gemdecrypt -key keyfile [file...]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *keyFile == "" {
+		usage()
+	}
+	b, err := ioutil.ReadFile(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gemdecrypt: %v\n", err)
+		os.Exit(2)
+	}
+	key, err := hex.DecodeString(string(bytes.TrimSpace(b)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gemdecrypt: %s: %v\n", *keyFile, err)
+		os.Exit(2)
+	}
+	status := 0
+	decrypt := func(name string, r io.Reader) {
+		_, err := io.Copy(os.Stdout, encrypt.NewReader(r, encrypt.AESKey(key)))
+		switch err {
+		case nil:
+		case encrypt.ErrTruncated:
+			fmt.Fprintf(os.Stderr, "gemdecrypt: warning: %s: %v\n", name, err)
+		default:
+			fmt.Fprintf(os.Stderr, "gemdecrypt: %s: %v\n", name, err)
+			status = 1
+		}
+	}
+	if flag.NArg() == 0 {
+		decrypt("stdin", os.Stdin)
+	}
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gemdecrypt: %v\n", err)
+			status = 1
+			continue
+		}
+		decrypt(name, f)
+		f.Close()
+	}
+	os.Exit(status)
+}
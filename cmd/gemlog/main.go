@@ -0,0 +1,642 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Gemlog filters, pretty-prints and converts logs written by package
+// github.com/go-gem/log.
+//
+// Usage:
+//
+//	gemlog [flags] [file...]
+//
+// Gemlog reads the files, or standard input, as text written by a Logger,
+// JSON written by JSONFormatter or logfmt written by LogfmtFormatter, each
+// in the format its first line looks like unless -in is given. The flags
+// of text logs are detected from their first record unless -flags is
+// given. Text logs are read with log.Scanner, which joins the lines of
+// multi-line messages and of records split by SetMaxLineLength.
+//
+// The flags are:
+//
+//	-level name
+//		show records of the level and above only: debug, info, warning,
+//		error or fatal
+//	-since time, -until time
+//		show records logged in the range only; a time is RFC 3339,
+//		"2006/01/02 15:04:05" in the local time zone, or a duration
+//		before now such as 1h
+//	-file substring
+//		show records whose caller file contains substring only
+//	-where expr
+//		show records matching expr only, which is key=value, key!=value,
+//		key~regexp or key; the key msg is the message. Repeatable.
+//	-in auto|text|json|logfmt
+//		format of the input
+//	-flags auto|list
+//		comma separated flags of text input: date, time, microseconds,
+//		longfile, shortfile, func, package, utc
+//	-out pretty|text|json|logfmt
+//		format of the output; pretty on terminals and text otherwise
+//	-out-flags list
+//		flags of text output; default date,time,microseconds,longfile
+//	-color auto|always|never
+//		colorize pretty output
+//	-f
+//		follow the file, across rotations, as tail -f does
+//
+// Gemlog uses the parsers and formatters of the package, so its formats
+// never drift from what the package writes.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-gem/log"
+)
+
+type whereFlag []string
+
+func (w *whereFlag) String() string     { return strings.Join(*w, ",") }
+func (w *whereFlag) Set(s string) error { *w = append(*w, s); return nil }
+
+var (
+	levelFlag    = flag.String("level", "", "minimum level")
+	sinceFlag    = flag.String("since", "", "show records logged at or after time")
+	untilFlag    = flag.String("until", "", "show records logged before time")
+	fileFlag     = flag.String("file", "", "show records whose caller file contains substring")
+	inFlag       = flag.String("in", "auto", "input format: auto, text, json or logfmt")
+	flagsFlag    = flag.String("flags", "auto", "flags of text input")
+	outFlag      = flag.String("out", "", "output format: pretty, text, json or logfmt")
+	outFlagsFlag = flag.String("out-flags", "date,time,microseconds,longfile", "flags of text output")
+	colorFlag    = flag.String("color", "auto", "colorize pretty output: auto, always or never")
+	followFlag   = flag.Bool("f", false, "follow the file")
+	whereFlags   whereFlag
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gemlog [flags] [file...]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "gemlog: "+format+"\n", args...)
+	os.Exit(2)
+}
+
+func main() {
+	flag.Var(&whereFlags, "where", "show records matching key=value, key!=value, key~regexp or key")
+	flag.Usage = usage
+	flag.Parse()
+
+	f, err := newFilter()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	out, err := newOutput(w)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	emit := func(r *log.Record) {
+		if f.match(r) {
+			out(r)
+		}
+	}
+	if *followFlag {
+		if flag.NArg() != 1 {
+			fatalf("-f needs exactly one file")
+		}
+		err := follow(flag.Arg(0), emit, w)
+		fatalf("%v", err)
+	}
+	status := 0
+	if flag.NArg() == 0 {
+		read(os.Stdin, emit)
+	}
+	for _, name := range flag.Args() {
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gemlog: %v\n", err)
+			status = 1
+			continue
+		}
+		read(file, emit)
+		file.Close()
+	}
+	w.Flush()
+	os.Exit(status)
+}
+
+// read passes the records of f to emit.
+func read(f io.Reader, emit func(*log.Record)) {
+	s := newScanner(f)
+	for s.Scan() {
+		emit(s.Record())
+	}
+	if err := s.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "gemlog: %v\n", err)
+	}
+}
+
+// follow passes the records of the named file to emit as they are
+// written, reopening the file when it is rotated and rereading it when it
+// is truncated. It returns only on errors.
+func follow(name string, emit func(*log.Record), w *bufio.Writer) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	s := newScanner(&tail{name: name, f: f})
+	for {
+		for s.Scan() {
+			emit(s.Record())
+		}
+		if err := s.Err(); err != nil {
+			return err
+		}
+		w.Flush()
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// A tail reads a file being written, returning only whole lines and
+// io.EOF when none is left. At the end of the file, it reopens the file if
+// it was rotated and rereads it if it was truncated.
+type tail struct {
+	name    string
+	f       *os.File
+	partial []byte // bytes read after the last newline
+	lines   []byte // whole lines not returned yet
+	buf     [32 << 10]byte
+}
+
+func (t *tail) Read(p []byte) (int, error) {
+	for len(t.lines) == 0 {
+		n, err := t.f.Read(t.buf[:])
+		t.partial = append(t.partial, t.buf[:n]...)
+		if i := bytes.LastIndexByte(t.partial, '\n'); i >= 0 {
+			t.lines = append(t.lines[:0], t.partial[:i+1]...)
+			t.partial = append(t.partial[:0], t.partial[i+1:]...)
+			break
+		}
+		if err == io.EOF {
+			if err := t.reopen(); err != nil {
+				return 0, err
+			}
+			if len(t.lines) == 0 {
+				return 0, io.EOF
+			}
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, t.lines)
+	t.lines = t.lines[n:]
+	return n, nil
+}
+
+// reopen reopens the file if it was rotated, after passing on the last
+// line of the rotated one, and rewinds it if it was truncated.
+func (t *tail) reopen() error {
+	cur, err := t.f.Stat()
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(t.name)
+	if err != nil {
+		return nil // being rotated.
+	}
+	if !os.SameFile(fi, cur) {
+		f, err := os.Open(t.name)
+		if err != nil {
+			return err
+		}
+		t.f.Close()
+		t.f = f
+		if len(t.partial) > 0 {
+			t.lines = append(append(t.lines[:0], t.partial...), '\n')
+			t.partial = t.partial[:0]
+		}
+		return nil
+	}
+	pos, err := t.f.Seek(0, io.SeekCurrent)
+	if err == nil && fi.Size() < pos {
+		_, err = t.f.Seek(0, io.SeekStart)
+		t.partial = t.partial[:0]
+	}
+	return err
+}
+
+// peekSize is the size of the buffer of a scanner, and so the maximum
+// length of the first line used to detect the format.
+const peekSize = 64 << 10
+
+// A scanner reads the records of a log in the format of its first line,
+// unless -in is given. Text logs are read with a log.Scanner.
+type scanner struct {
+	br     *bufio.Reader
+	format string
+	flag   int
+	detect bool         // detect flag from the first text record
+	text   *log.Scanner // once the format is known to be text
+	rec    log.Record
+	err    error
+}
+
+func newScanner(r io.Reader) *scanner {
+	s := &scanner{br: bufio.NewReaderSize(r, peekSize), format: *inFlag, detect: *flagsFlag == "auto"}
+	if !s.detect {
+		flag, err := parseFlags(*flagsFlag)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		s.flag = flag
+	}
+	return s
+}
+
+// Scan advances to the next record. At the end of the input it returns
+// false, and may be called again if the input grows.
+func (s *scanner) Scan() bool {
+	if s.text == nil && (s.format == "auto" || s.format == "text") {
+		line, ok := s.firstLine()
+		if !ok {
+			return false
+		}
+		s.start(line)
+	}
+	if s.text != nil {
+		if !s.text.Scan() {
+			s.err = s.text.Err()
+			return false
+		}
+		s.rec = s.text.Record()
+		return true
+	}
+	for {
+		line, err := s.br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			s.err = err
+		}
+		if line == "" {
+			return false
+		}
+		if line = strings.TrimRight(line, "\r\n"); line == "" {
+			continue
+		}
+		var rec log.Record
+		if s.format == "json" {
+			rec, err = log.ParseJSON([]byte(line))
+		} else {
+			rec, err = log.ParseLogfmt(line)
+		}
+		if err != nil {
+			rec = log.Record{Message: line}
+		}
+		s.rec = rec
+		return true
+	}
+}
+
+// firstLine returns the first line of the input without consuming it.
+func (s *scanner) firstLine() (string, bool) {
+	for n := 1; ; n = s.br.Buffered() + 1 {
+		_, err := s.br.Peek(n)
+		b, _ := s.br.Peek(s.br.Buffered())
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			return strings.TrimSuffix(string(b[:i]), "\r"), true
+		}
+		if err != nil {
+			if err != io.EOF && err != bufio.ErrBufferFull {
+				s.err = err
+			}
+			return string(b), len(b) > 0
+		}
+	}
+}
+
+// start sets the format of the input from its first line.
+func (s *scanner) start(line string) {
+	if s.format == "auto" {
+		switch {
+		case strings.HasPrefix(line, "{"):
+			s.format = "json"
+		case strings.HasPrefix(line, "time="):
+			s.format = "logfmt"
+		default:
+			s.format = "text"
+		}
+	}
+	if s.format != "text" {
+		return
+	}
+	if s.detect {
+		s.flag = detectFlags(line)
+	}
+	s.text = log.NewScanner(s.br, s.flag)
+}
+
+// Record returns the record read by the last call to Scan.
+func (s *scanner) Record() *log.Record {
+	return &s.rec
+}
+
+// Err returns the first error other than io.EOF met by the scanner.
+func (s *scanner) Err() error {
+	return s.err
+}
+
+var (
+	prefixRE = regexp.MustCompile(`^(DEBU|INFO|WARN|ERRO|FATA) `)
+	dateRE   = regexp.MustCompile(`^\d{4}/\d\d/\d\d `)
+	timeRE   = regexp.MustCompile(`^\d\d:\d\d:\d\d(\.\d{6})? `)
+	fileRE   = regexp.MustCompile(`^\S+:\d+: `)
+)
+
+// detectFlags returns the flags of the header of line.
+func detectFlags(line string) int {
+	flag := 0
+	s := prefixRE.ReplaceAllString(line, "")
+	if m := dateRE.FindString(s); m != "" {
+		flag |= log.Ldate
+		s = s[len(m):]
+	}
+	if m := timeRE.FindStringSubmatch(s); m != nil {
+		flag |= log.Ltime
+		if m[1] != "" {
+			flag |= log.Lmicroseconds
+		}
+		s = s[len(m[0]):]
+	}
+	if fileRE.MatchString(s) {
+		flag |= log.Llongfile
+	}
+	return flag
+}
+
+var flagNames = map[string]int{
+	"date":         log.Ldate,
+	"time":         log.Ltime,
+	"microseconds": log.Lmicroseconds,
+	"longfile":     log.Llongfile,
+	"shortfile":    log.Lshortfile,
+	"relfile":      log.Lrelfile,
+	"utc":          log.LUTC,
+	"sortfields":   log.Lsortfields,
+	"singleline":   log.Lsingleline,
+	"sanitize":     log.Lsanitize,
+	"func":         log.Lfunc,
+	"package":      log.Lpackage,
+}
+
+func parseFlags(s string) (int, error) {
+	flag := 0
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		f, ok := flagNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown flag %q", name)
+		}
+		flag |= f
+	}
+	return flag, nil
+}
+
+// A filter selects records.
+type filter struct {
+	level        int
+	since, until time.Time
+	file         string
+	where        []func(*log.Record) bool
+}
+
+func newFilter() (*filter, error) {
+	f := &filter{file: *fileFlag}
+	if *levelFlag != "" {
+		level, err := log.ParseLevel(*levelFlag)
+		if err != nil {
+			return nil, err
+		}
+		f.level = level
+	}
+	var err error
+	if f.since, err = parseTime(*sinceFlag); err != nil {
+		return nil, err
+	}
+	if f.until, err = parseTime(*untilFlag); err != nil {
+		return nil, err
+	}
+	for _, expr := range whereFlags {
+		m, err := parseWhere(expr)
+		if err != nil {
+			return nil, err
+		}
+		f.where = append(f.where, m)
+	}
+	return f, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006/01/02 15:04:05", "2006/01/02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("bad time %q", s)
+}
+
+// parseWhere returns the matcher of a field expression.
+func parseWhere(expr string) (func(*log.Record) bool, error) {
+	i := strings.IndexAny(expr, "=!~")
+	if i < 0 {
+		return func(r *log.Record) bool {
+			_, ok := value(r, expr)
+			return ok
+		}, nil
+	}
+	key, op, want := expr[:i], expr[i:i+1], expr[i+1:]
+	switch {
+	case op == "!" && strings.HasPrefix(want, "="):
+		want = want[1:]
+		return func(r *log.Record) bool {
+			v, ok := value(r, key)
+			return !ok || v != want
+		}, nil
+	case op == "=":
+		return func(r *log.Record) bool {
+			v, ok := value(r, key)
+			return ok && v == want
+		}, nil
+	case op == "~":
+		re, err := regexp.Compile(want)
+		if err != nil {
+			return nil, err
+		}
+		return func(r *log.Record) bool {
+			v, ok := value(r, key)
+			return ok && re.MatchString(v)
+		}, nil
+	}
+	return nil, fmt.Errorf("bad expression %q", expr)
+}
+
+// value returns the text of the field key of r, or of its message if key
+// is msg.
+func value(r *log.Record, key string) (string, bool) {
+	if key == "msg" {
+		return r.Message, true
+	}
+	for i := len(r.Fields) - 1; i >= 0; i-- {
+		if r.Fields[i].Key == key {
+			return fmt.Sprint(r.Fields[i].Value), true
+		}
+	}
+	return "", false
+}
+
+func (f *filter) match(r *log.Record) bool {
+	if f.level != 0 && r.Level < f.level {
+		return false
+	}
+	if !f.since.IsZero() && (r.Time.IsZero() || r.Time.Before(f.since)) {
+		return false
+	}
+	if !f.until.IsZero() && (r.Time.IsZero() || !r.Time.Before(f.until)) {
+		return false
+	}
+	if f.file != "" && !strings.Contains(r.File, f.file) {
+		return false
+	}
+	for _, m := range f.where {
+		if !m(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// newOutput returns the function writing records to w.
+func newOutput(w io.Writer) (func(*log.Record), error) {
+	terminal := false
+	if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		terminal = os.Getenv("TERM") != "dumb"
+	}
+	format := *outFlag
+	if format == "" {
+		format = "text"
+		if terminal {
+			format = "pretty"
+		}
+	}
+	var formatter log.Formatter
+	switch format {
+	case "text":
+		flag, err := parseFlags(*outFlagsFlag)
+		if err != nil {
+			return nil, err
+		}
+		formatter = textFormatter(flag)
+	case "json":
+		formatter = log.JSONFormatter{}
+	case "logfmt":
+		formatter = log.LogfmtFormatter{}
+	case "pretty":
+		var color bool
+		switch *colorFlag {
+		case "always":
+			color = true
+		case "auto":
+			color = terminal && os.Getenv("NO_COLOR") == ""
+		case "never":
+		default:
+			return nil, fmt.Errorf("bad -color %q", *colorFlag)
+		}
+		formatter = prettyFormatter(color)
+	default:
+		return nil, fmt.Errorf("bad -out %q", format)
+	}
+	var buf []byte
+	return func(r *log.Record) {
+		buf = formatter.Format(buf[:0], r)
+		w.Write(buf)
+	}, nil
+}
+
+// textFormatter formats records as log.TextFormatter does, without the
+// file or the function of records that have none.
+type textFormatter int
+
+func (f textFormatter) Format(buf []byte, r *log.Record) []byte {
+	flag := int(f)
+	if r.File == "" {
+		flag &^= log.Llongfile | log.Lshortfile | log.Lrelfile
+	}
+	if r.Function == "" {
+		flag &^= log.Lfunc | log.Lpackage
+	}
+	return log.TextFormatter{Flag: flag}.Format(buf, r)
+}
+
+// prettyFormatter formats records for terminals, with colors if set.
+// Messages and fields are sanitized.
+type prettyFormatter bool
+
+var levelColors = map[int]string{
+	log.LevelDebug:   "\x1b[90m",
+	log.LevelInfo:    "\x1b[32m",
+	log.LevelWarning: "\x1b[33m",
+	log.LevelError:   "\x1b[31m",
+	log.LevelFatal:   "\x1b[1;35m",
+}
+
+const (
+	colorDim   = "\x1b[2m"
+	colorReset = "\x1b[0m"
+)
+
+func (color prettyFormatter) Format(buf []byte, r *log.Record) []byte {
+	paint := func(c, s string) {
+		if color {
+			buf = append(buf, c...)
+			buf = append(buf, s...)
+			buf = append(buf, colorReset...)
+		} else {
+			buf = append(buf, s...)
+		}
+		buf = append(buf, ' ')
+	}
+	if !r.Time.IsZero() {
+		paint(colorDim, r.Time.Format("2006-01-02 15:04:05.000000"))
+	}
+	if name := log.LevelName(r.Level); name != "" {
+		paint(levelColors[r.Level], fmt.Sprintf("%-7s", strings.ToUpper(name)))
+	}
+	if r.File != "" {
+		paint(colorDim, fmt.Sprintf("%s:%d", r.File, r.Line))
+	}
+	if r.Function != "" {
+		paint(colorDim, r.Function)
+	}
+	body := log.Record{Message: r.Message, Fields: r.Fields}
+	return log.TextFormatter{Flag: log.Lsanitize}.Format(buf, &body)
+}
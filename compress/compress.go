@@ -0,0 +1,167 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package compress provides a gzip writer for logs that survives crashes.
+//
+// A plain gzip.Writer keeps its stream open until Close, so a killed
+// process leaves a file without its trailer, and an unflushed one loses
+// the buffered data. The Writer instead buffers the uncompressed bytes and
+// writes them, on a size and time cadence, as a complete gzip member in a
+// single call to Write. A file is a sequence of members, which gzip, zcat
+// and gzip.Reader read as one stream, and a crash loses at most the bytes
+// of the current interval.
+//
+//	w := compress.NewWriter(f, nil)
+//	logger := log.New(w, log.LstdFlags, log.LevelAll)
+//	defer f.Close()
+//	defer logger.Close()
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Options configure a Writer. Zero values select the defaults.
+type Options struct {
+	Level         int           // gzip compression level; default gzip.DefaultCompression
+	NoCompression bool          // store the bytes uncompressed, ignoring Level
+	FlushBytes    int           // uncompressed bytes written as a member; default 256 KiB
+	FlushInterval time.Duration // time bytes may wait in the buffer; default 1s
+}
+
+// ErrClosed is returned by writes to a closed Writer.
+var ErrClosed = errors.New("compress: write to closed Writer")
+
+// A Writer compresses the bytes written to it into gzip members. It is
+// safe for concurrent use.
+type Writer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	opts   Options
+	buf    []byte // uncompressed bytes of the next member
+	member bytes.Buffer
+	zw     *gzip.Writer
+	timer  *time.Timer // running while buf is not empty
+	err    error       // error of the last timed flush, until reported
+	closed bool
+}
+
+// NewWriter returns a Writer compressing to w. opts may be nil. A
+// non-zero level of opts must be valid for gzip.NewWriterLevel.
+func NewWriter(w io.Writer, opts *Options) *Writer {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	switch {
+	case o.NoCompression:
+		o.Level = gzip.NoCompression
+	case o.Level == 0:
+		o.Level = gzip.DefaultCompression
+	}
+	if o.FlushBytes <= 0 {
+		o.FlushBytes = 256 << 10
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = time.Second
+	}
+	return &Writer{w: w, opts: o}
+}
+
+// Write implements io.Writer. It fails only if the member it writes,
+// when the buffer is full, cannot be written; the errors of the flushes
+// by the timer are returned by the next Flush or Close.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if len(w.buf) == 0 {
+		if w.timer == nil {
+			w.timer = time.AfterFunc(w.opts.FlushInterval, w.timedFlush)
+		} else {
+			w.timer.Reset(w.opts.FlushInterval)
+		}
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.opts.FlushBytes {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *Writer) timedFlush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.flush(); err != nil {
+		w.err = err
+	}
+}
+
+// flush writes the buffered bytes as a gzip member. w.mu must be held.
+func (w *Writer) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.member.Reset()
+	if w.zw == nil {
+		zw, err := gzip.NewWriterLevel(&w.member, w.opts.Level)
+		if err != nil {
+			return err
+		}
+		w.zw = zw
+	} else {
+		w.zw.Reset(&w.member)
+	}
+	w.zw.Write(w.buf) // writes to a bytes.Buffer do not fail.
+	w.zw.Close()
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(w.member.Bytes())
+	return err
+}
+
+// timedErr returns err, or else the error of the last timed flush not
+// reported yet. w.mu must be held.
+func (w *Writer) timedErr(err error) error {
+	if err == nil {
+		err = w.err
+	}
+	w.err = nil
+	return err
+}
+
+// Flush writes the buffered bytes as a gzip member. It also returns the
+// error of a flush by the timer since the last Flush, if any.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.timedErr(w.flush())
+}
+
+// Close flushes the Writer, as Flush does, and stops its timer. It does
+// not close the underlying writer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.flush()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	return w.timedErr(err)
+}
@@ -0,0 +1,167 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gem/log"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of timers.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.b.Bytes()...)
+}
+
+func gunzip(t *testing.T, b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(plain)
+}
+
+func TestFlushBytes(t *testing.T) {
+	var b syncBuffer
+	w := NewWriter(&b, &Options{FlushBytes: 20, FlushInterval: time.Hour})
+	l := log.New(w, 0, log.LevelAll)
+	l.Info("one")
+	if len(b.Bytes()) != 0 {
+		t.Error("flushed before FlushBytes")
+	}
+	l.Info("two three four")
+	// the file is readable without Close: the process could be killed here.
+	if got := gunzip(t, b.Bytes()); got != "INFO one\nINFO two three four\n" {
+		t.Errorf("got %q", got)
+	}
+	l.Info("five")
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := gunzip(t, b.Bytes()); !strings.HasSuffix(got, "INFO five\n") {
+		t.Errorf("got %q after Flush", got)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Output(1, "closed", ""); err != ErrClosed {
+		t.Errorf("Output after Close = %v", err)
+	}
+}
+
+func TestFlushInterval(t *testing.T) {
+	var b syncBuffer
+	w := NewWriter(&b, &Options{FlushInterval: 10 * time.Millisecond})
+	defer w.Close()
+	w.Write([]byte("timed\n"))
+	deadline := time.Now().Add(5 * time.Second)
+	for gunzip(t, b.Bytes()) != "timed\n" {
+		if time.Now().After(deadline) {
+			t.Fatal("not flushed by the timer")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	for _, test := range []struct {
+		opts  *Options
+		plain bool
+	}{
+		{nil, false},
+		{&Options{FlushBytes: 1 << 20}, false},
+		{&Options{Level: gzip.BestSpeed}, false},
+		{&Options{NoCompression: true}, true},
+	} {
+		var b syncBuffer
+		w := NewWriter(&b, test.opts)
+		w.Write([]byte(strings.Repeat("repeated ", 100)))
+		w.Close()
+		if plain := bytes.Contains(b.Bytes(), []byte("repeated repeated")); plain != test.plain {
+			t.Errorf("options %+v: stored uncompressed %v, want %v", test.opts, plain, test.plain)
+		}
+		if got := gunzip(t, b.Bytes()); got != strings.Repeat("repeated ", 100) {
+			t.Errorf("options %+v: got %q", test.opts, got)
+		}
+	}
+}
+
+// failWriter fails while failing is set.
+type failWriter struct {
+	syncBuffer
+	failing bool
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	failing := w.failing
+	w.mu.Unlock()
+	if failing {
+		return 0, errors.New("disk full")
+	}
+	return w.syncBuffer.Write(p)
+}
+
+func TestTimedFlushError(t *testing.T) {
+	u := &failWriter{failing: true}
+	w := NewWriter(u, &Options{FlushInterval: 10 * time.Millisecond})
+	w.Write([]byte("lost\n"))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w.mu.Lock()
+		failed := w.err != nil
+		w.mu.Unlock()
+		if failed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("not flushed by the timer")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	u.mu.Lock()
+	u.failing = false
+	u.mu.Unlock()
+	// the next record does not pay for the failed timed flush.
+	if _, err := w.Write([]byte("kept\n")); err != nil {
+		t.Errorf("Write after a failed timed flush = %v", err)
+	}
+	if err := w.Flush(); err == nil || err.Error() != "disk full" {
+		t.Errorf("Flush = %v, want the timed flush error", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+	if got := gunzip(t, u.Bytes()); got != "kept\n" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+	"strings"
+)
+
+// Keys of the fields attached by WithContext.
+const (
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+)
+
+// A TraceExtractor returns the trace and span IDs carried by ctx, if any.
+// It lets any tracing library correlate records with traces without this
+// package depending on it.
+type TraceExtractor func(ctx context.Context) (traceID, spanID string, ok bool)
+
+// SetTraceExtractor sets the TraceExtractor used by WithContext. A nil
+// extractor means TraceparentExtractor.
+func (l *Logger) SetTraceExtractor(e TraceExtractor) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.extractor = e
+}
+
+// WithContext returns a Logger that attaches the trace and span IDs
+// carried by ctx, as extracted by the TraceExtractor of l, to every record
+// under TraceIDKey and SpanIDKey. They come before the other fields and
+// replace any previously attached. If ctx carries no trace, l is returned.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	l.mu.Lock()
+	extract := l.extractor
+	l.mu.Unlock()
+	if extract == nil {
+		extract = TraceparentExtractor
+	}
+	traceID, spanID, ok := extract(ctx)
+	if !ok {
+		return l
+	}
+	fields := make([]Field, 0, len(l.fields)+2)
+	fields = append(fields, Field{Key: TraceIDKey, Value: traceID})
+	if spanID != "" {
+		fields = append(fields, Field{Key: SpanIDKey, Value: spanID})
+	}
+	for _, f := range l.fields {
+		if f.Key != TraceIDKey && f.Key != SpanIDKey {
+			fields = append(fields, f)
+		}
+	}
+	return &Logger{core: l.core, fields: fields, counters: l.counters}
+}
+
+type traceparentKey struct{}
+
+// ContextWithTraceparent returns a copy of ctx carrying the value of a W3C
+// traceparent header, for TraceparentExtractor.
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	return context.WithValue(ctx, traceparentKey{}, traceparent)
+}
+
+// TraceparentExtractor is a TraceExtractor for the W3C traceparent values
+// stored by ContextWithTraceparent.
+func TraceparentExtractor(ctx context.Context) (traceID, spanID string, ok bool) {
+	traceparent, _ := ctx.Value(traceparentKey{}).(string)
+	return ParseTraceparent(traceparent)
+}
+
+// ParseTraceparent returns the trace and span IDs of a W3C traceparent
+// value such as 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func ParseTraceparent(traceparent string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || !isHexID(parts[0], 2) || parts[0] == "ff" {
+		return "", "", false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", "", false
+	}
+	if !isHexID(parts[1], 32) || !isHexID(parts[2], 16) || !isHexID(parts[3], 2) {
+		return "", "", false
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// isHexID reports whether s is n lower case hexadecimal digits.
+func isHexID(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+type loggerKey struct{}
+
+// NewContext returns a copy of ctx carrying l.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the Logger carried by ctx, or the standard logger
+// if there is none.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+	return std
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		traceparent     string
+		traceID, spanID string
+		ok              bool
+	}{
+		{testTraceparent, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "", "", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "", "", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", "", "", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", "", "", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "", "", false},
+		{"", "", "", false},
+	}
+	for _, test := range tests {
+		traceID, spanID, ok := ParseTraceparent(test.traceparent)
+		if traceID != test.traceID || spanID != test.spanID || ok != test.ok {
+			t.Errorf("ParseTraceparent(%q) = %q, %q, %v", test.traceparent, traceID, spanID, ok)
+		}
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	formatters := []struct {
+		f    Formatter
+		want string
+	}{
+		{TextFormatter{}, "INFO hello trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7 user=gopher\n"},
+		{LogfmtFormatter{}, "time=2009-11-10T23:00:00Z level=info msg=hello trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7 user=gopher\n"},
+		{JSONFormatter{}, `{"time":"2009-11-10T23:00:00Z","level":"info","msg":"hello","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","user":"gopher"}` + "\n"},
+	}
+	ctx := ContextWithTraceparent(context.Background(), testTraceparent)
+	for _, test := range formatters {
+		var b bytes.Buffer
+		l := New(nil, 0, LevelAll)
+		l.SetHandler(NewWriterHandler(&b, test.f))
+		l.SetClock(func() time.Time { return time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC) })
+		// the trace fields of an earlier context are replaced.
+		other := ContextWithTraceparent(context.Background(), "00-11111111111111111111111111111111-2222222222222222-01")
+		l.WithContext(other).With("user", "gopher").WithContext(ctx).Info("hello")
+		if b.String() != test.want {
+			t.Errorf("got  %s\nwant %s", b.String(), test.want)
+		}
+	}
+
+	l := New(nil, 0, LevelAll)
+	if l.WithContext(context.Background()) != l {
+		t.Error("expected the same logger for a context without trace")
+	}
+}
+
+type spanKey struct{}
+
+func TestSetTraceExtractor(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b, 0, LevelAll)
+	l.SetTraceExtractor(func(ctx context.Context) (string, string, bool) {
+		span, ok := ctx.Value(spanKey{}).(string)
+		return "trace", span, ok
+	})
+	l.WithContext(context.WithValue(context.Background(), spanKey{}, "span")).Info("hello")
+	if expect := "INFO hello trace_id=trace span_id=span\n"; b.String() != expect {
+		t.Errorf("log output should match %q is %q", expect, b.String())
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != std {
+		t.Error("expected the standard logger")
+	}
+	l := New(nil, 0, LevelAll)
+	if FromContext(NewContext(context.Background(), l)) != l {
+		t.Error("expected the logger of the context")
+	}
+}
@@ -0,0 +1,404 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package encrypt provides a writer that encrypts logs at rest, and the
+// reader that decrypts them.
+//
+// A stream is made of segments, one per Writer, so that a file stays
+// appendable: each new Writer starts a segment with its own random AES-256
+// data key, wrapped by a KeyWrapper holding the user's key. A segment is
+//
+//	"GEMENC1\n" | uint16 length | wrapped data key | chunk...
+//
+// where each chunk is a big-endian uint32 length, whose high bit marks the
+// final chunk written by Close, followed by the AES-GCM sealed bytes. The
+// nonce of a chunk is its index in the segment and its final bit, and the
+// additional data the segment header, so chunks cannot be reordered, moved
+// or dropped from the middle of a segment without detection.
+//
+// Chunks are independently decryptable: after a crash, every chunk written
+// completely is recovered, and a segment appended after a torn chunk is
+// found again by the Reader.
+//
+//	w, err := encrypt.NewWriter(f, encrypt.AESKey(key), nil)
+//	logger := log.New(w, log.LstdFlags, log.LevelAll)
+package encrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Magic starts every segment.
+const Magic = "GEMENC1\n"
+
+const (
+	dataKeySize  = 32
+	finalBit     = 1 << 31
+	maxChunkSize = 1 << 24 // sealed bytes
+	maxPlaintext = maxChunkSize - 16
+)
+
+// Errors returned by a Reader.
+var (
+	ErrTruncated = errors.New("encrypt: truncated stream")
+	ErrCorrupt   = errors.New("encrypt: corrupt stream")
+)
+
+// A KeyWrapper encrypts and decrypts the data keys of the segments, for
+// example with a key held by a key management service.
+type KeyWrapper interface {
+	WrapKey(key []byte) ([]byte, error)
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+// AESKey is a 16, 24 or 32-byte AES key that wraps data keys with AES-GCM.
+type AESKey []byte
+
+// WrapKey implements KeyWrapper.
+func (k AESKey) WrapKey(key []byte) ([]byte, error) {
+	aead, err := newGCM(k)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(key)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, key, nil), nil
+}
+
+// UnwrapKey implements KeyWrapper.
+func (k AESKey) UnwrapKey(wrapped []byte) ([]byte, error) {
+	aead, err := newGCM(k)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrCorrupt
+	}
+	key, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("encrypt: cannot unwrap the data key")
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce of the chunk with the given index.
+func chunkNonce(nonce []byte, index uint64, final bool) []byte {
+	for i := range nonce {
+		nonce[i] = 0
+	}
+	if final {
+		nonce[3] = 1
+	}
+	binary.BigEndian.PutUint64(nonce[4:], index)
+	return nonce
+}
+
+// Options configure a Writer.
+type Options struct {
+	// ChunkSize is the number of bytes buffered before they are sealed
+	// into a chunk. Zero seals every Write into its own chunk, so that
+	// every record survives a crash.
+	ChunkSize int
+}
+
+// A Writer encrypts a segment to an underlying writer. It is safe for
+// concurrent use. Once a write to the underlying writer fails, the Writer
+// returns that error from every call, since sealing other bytes under the
+// nonce of the failed chunk would break AES-GCM; a new Writer starts a new
+// segment.
+type Writer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	size   int
+	index  uint64
+	nonce  []byte
+	buf    []byte // plaintext not yet sealed
+	out    []byte // sealed chunk
+	err    error  // of the first failed write
+	closed bool
+}
+
+// NewWriter generates a data key, wraps it with kw and writes the header
+// of a new segment to w.
+func NewWriter(w io.Writer, kw KeyWrapper, opts *Options) (*Writer, error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.ChunkSize > maxPlaintext {
+		o.ChunkSize = maxPlaintext
+	}
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	wrapped, err := kw.WrapKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) > 0xffff {
+		return nil, errors.New("encrypt: wrapped data key too long")
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, len(Magic)+2+len(wrapped))
+	header = append(header, Magic...)
+	header = append(header, byte(len(wrapped)>>8), byte(len(wrapped)))
+	header = append(header, wrapped...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:      w,
+		aead:   aead,
+		header: header,
+		size:   o.ChunkSize,
+		nonce:  make([]byte, aead.NonceSize()),
+	}, nil
+}
+
+// Write implements io.Writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, errors.New("encrypt: write to closed Writer")
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		m := len(p)
+		if room := maxPlaintext - len(w.buf); m > room {
+			m = room
+		}
+		w.buf = append(w.buf, p[:m]...)
+		p = p[m:]
+		if len(w.buf) >= w.size {
+			if err := w.seal(false); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// seal writes the buffered bytes as a chunk. w.mu must be held.
+func (w *Writer) seal(final bool) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) == 0 && !final {
+		return nil
+	}
+	w.out = append(w.out[:0], 0, 0, 0, 0)
+	w.out = w.aead.Seal(w.out, chunkNonce(w.nonce, w.index, final), w.buf, w.header)
+	length := uint32(len(w.out) - 4)
+	if final {
+		length |= finalBit
+	}
+	binary.BigEndian.PutUint32(w.out, length)
+	if _, err := w.w.Write(w.out); err != nil {
+		w.err = err
+		return err
+	}
+	w.index++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Flush seals the buffered bytes into a chunk.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	return w.seal(false)
+}
+
+// Close seals the buffered bytes and writes the final chunk of the
+// segment. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.seal(false); err != nil {
+		return err
+	}
+	return w.seal(true)
+}
+
+// A Reader decrypts the segments of a stream.
+//
+// It returns ErrTruncated rather than io.EOF at the end of a stream that
+// has a segment without its final chunk, after the data of every complete
+// chunk, and ErrCorrupt for chunks that fail to authenticate.
+type Reader struct {
+	r         *bufio.Reader
+	kw        KeyWrapper
+	aead      cipher.AEAD // of the current segment, nil between segments
+	header    []byte
+	index     uint64
+	nonce     []byte
+	final     bool // whether the current segment ended
+	truncated bool
+	chunk     []byte
+	buf       []byte // plaintext not yet read
+	err       error
+}
+
+// NewReader returns a Reader decrypting r with the data keys unwrapped
+// by kw.
+func NewReader(r io.Reader, kw KeyWrapper) *Reader {
+	return &Reader{r: bufio.NewReader(r), kw: kw}
+}
+
+// Read implements io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.next()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// end returns the error ending the stream.
+func (r *Reader) end() error {
+	if r.truncated || r.aead != nil && !r.final {
+		return ErrTruncated
+	}
+	return io.EOF
+}
+
+// next reads the next chunk into r.buf, or the header of a segment.
+func (r *Reader) next() error {
+	b, err := r.r.Peek(len(Magic))
+	if len(b) == 0 && err == io.EOF {
+		return r.end()
+	}
+	if string(b) == Magic {
+		if r.aead != nil && !r.final {
+			r.truncated = true
+		}
+		return r.readHeader()
+	}
+	if r.aead == nil || r.final {
+		if err == io.EOF && bytes.HasPrefix([]byte(Magic), b) {
+			r.truncated = true
+			return r.end()
+		}
+		return ErrCorrupt
+	}
+	var length [4]byte
+	if _, err := io.ReadFull(r.r, length[:]); err != nil {
+		r.truncated = true
+		return r.end()
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	final := n&finalBit != 0
+	n &^= finalBit
+	if n > maxChunkSize {
+		return r.resync(length[:], false)
+	}
+	if cap(r.chunk) < int(n) {
+		r.chunk = make([]byte, n)
+	}
+	r.chunk = r.chunk[:n]
+	if m, err := io.ReadFull(r.r, r.chunk); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return r.resync(append(length[:], r.chunk[:m]...), true)
+		}
+		return err
+	}
+	plain, err := r.aead.Open(r.buf[:0], chunkNonce(r.nonce, r.index, final), r.chunk, r.header)
+	if err != nil {
+		return r.resync(append(length[:], r.chunk...), false)
+	}
+	r.buf = plain
+	r.index++
+	r.final = final
+	return nil
+}
+
+// resync handles the bytes b of a chunk that failed to decrypt, or that
+// ends the stream early if short is set. It resumes reading at the next
+// segment, as written after a chunk torn by a crash, if there is one. The
+// stream is then truncated, or corrupt if the chunk was complete and no
+// segment follows.
+func (r *Reader) resync(b []byte, short bool) error {
+	r.r = bufio.NewReader(io.MultiReader(bytes.NewReader(b[1:]), r.r))
+	for {
+		p, err := r.r.Peek(len(Magic))
+		if string(p) == Magic {
+			r.truncated = true
+			return r.readHeader()
+		}
+		if err != nil {
+			if !short {
+				return ErrCorrupt
+			}
+			r.truncated = true
+			return r.end()
+		}
+		r.r.Discard(1)
+	}
+}
+
+// readHeader reads the header of a segment.
+func (r *Reader) readHeader() error {
+	var fixed [len(Magic) + 2]byte
+	if _, err := io.ReadFull(r.r, fixed[:]); err != nil {
+		r.truncated = true
+		return r.end()
+	}
+	n := int(fixed[len(Magic)])<<8 | int(fixed[len(Magic)+1])
+	header := make([]byte, len(fixed)+n)
+	copy(header, fixed[:])
+	if _, err := io.ReadFull(r.r, header[len(fixed):]); err != nil {
+		r.truncated = true
+		return r.end()
+	}
+	key, err := r.kw.UnwrapKey(header[len(fixed):])
+	if err != nil {
+		return err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	r.aead, r.header, r.index, r.final = aead, header, 0, false
+	r.nonce = make([]byte, aead.NonceSize())
+	return nil
+}
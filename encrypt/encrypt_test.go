@@ -0,0 +1,134 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/go-gem/log"
+)
+
+var key = AESKey(bytes.Repeat([]byte{7}, 32))
+
+// segment writes the lines to a new segment of b, and closes it if close
+// is set.
+func segment(t *testing.T, b *bytes.Buffer, opts *Options, close bool, lines ...string) {
+	w, err := NewWriter(b, key, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := log.New(w, 0, log.LevelAll)
+	for _, line := range lines {
+		l.Info(line)
+	}
+	if close {
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func decrypt(b []byte, kw KeyWrapper) (string, error) {
+	plain, err := ioutil.ReadAll(NewReader(bytes.NewReader(b), kw))
+	return string(plain), err
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, opts := range []*Options{nil, {ChunkSize: 20}, {ChunkSize: 1 << 20}} {
+		var b bytes.Buffer
+		segment(t, &b, opts, true, "one", "two")
+		segment(t, &b, opts, true, strings.Repeat("x", 100))
+		got, err := decrypt(b.Bytes(), key)
+		if want := "INFO one\nINFO two\nINFO " + strings.Repeat("x", 100) + "\n"; got != want || err != nil {
+			t.Errorf("%+v: decrypt = %q, %v, want %q", opts, got, err, want)
+		}
+		if bytes.Contains(b.Bytes(), []byte("two")) {
+			t.Errorf("%+v: plaintext in the stream", opts)
+		}
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	var b bytes.Buffer
+	segment(t, &b, nil, true, "one", "two", "three")
+	full := b.Bytes()
+	want := "INFO one\nINFO two\nINFO three\n"
+	for n := 0; n < len(full); n++ {
+		got, err := decrypt(full[:n], key)
+		if err != ErrTruncated && !(n == 0 && err == nil) {
+			t.Fatalf("decrypt of %d bytes: %v", n, err)
+		}
+		if !strings.HasPrefix(want, got) || got != "" && !strings.HasSuffix(got, "\n") {
+			t.Fatalf("decrypt of %d bytes = %q", n, got)
+		}
+	}
+}
+
+func TestTornChunk(t *testing.T) {
+	var b bytes.Buffer
+	segment(t, &b, nil, false, "one", "two")
+	b.Truncate(b.Len() - 5) // crash while writing "two"
+	segment(t, &b, nil, true, "three")
+	got, err := decrypt(b.Bytes(), key)
+	if got != "INFO one\nINFO three\n" || err != ErrTruncated {
+		t.Errorf("decrypt = %q, %v", got, err)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	var b bytes.Buffer
+	segment(t, &b, nil, true, "one", "two")
+	data := b.Bytes()
+	data[len(data)-30] ^= 1
+	if got, err := decrypt(data, key); got != "INFO one\n" || err != ErrCorrupt {
+		t.Errorf("decrypt = %q, %v", got, err)
+	}
+	if _, err := decrypt(data, AESKey(bytes.Repeat([]byte{8}, 32))); err == nil {
+		t.Error("expected an error with the wrong key")
+	}
+}
+
+// failOnce fails the write of the given index.
+type failOnce struct {
+	bytes.Buffer
+	fail, n int
+}
+
+func (w *failOnce) Write(p []byte) (int, error) {
+	w.n++
+	if w.n == w.fail {
+		return 0, errors.New("transient")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestWriteFailure(t *testing.T) {
+	u := &failOnce{fail: 2} // the header, then the first chunk.
+	w, err := NewWriter(u, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := u.Len()
+	if _, err := w.Write([]byte("first\n")); err == nil {
+		t.Fatal("expected the write to fail")
+	}
+	// the nonce of the failed chunk is never used again.
+	if _, err := w.Write([]byte("second\n")); err == nil || err.Error() != "transient" {
+		t.Errorf("Write after failure = %v", err)
+	}
+	if err := w.Flush(); err == nil {
+		t.Error("Flush after failure succeeded")
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close after failure succeeded")
+	}
+	if u.Len() != header {
+		t.Errorf("%d bytes written after the failure", u.Len()-header)
+	}
+}
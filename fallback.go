@@ -0,0 +1,101 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"io"
+	"time"
+)
+
+// An ErrorHandler handles the errors that occur while logging the record
+// r: the failed writes to the output and to the fallback, the errors
+// returned by the Handler and by the hooks. It is called without holding
+// the Logger's lock, so it may log, but should not to the failing output.
+type ErrorHandler func(err error, r Record)
+
+// SetErrorHandler sets the ErrorHandler of the logger. A nil ErrorHandler
+// means printing the errors to standard error.
+func (l *Logger) SetErrorHandler(h ErrorHandler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errorHandler = h
+}
+
+// SetFallback sets a fallback output, such as os.Stderr, for the records
+// that cannot be written to the output or handled by the Handler. Every
+// record that fails on the primary output is written to w.
+//
+// After failures consecutive failures of the primary output, the Logger
+// writes the following records to w only, as a circuit breaker would. Once every retry interval, as measured by the clock of
+// the Logger, the next record is tried on the primary output again, which
+// is used from then on if the record succeeds. Records are formatted as by
+// SetOutput.
+//
+// Records that are neither written nor handled nor written to the fallback
+// are counted as lost in Stats. A nil w disables the fallback.
+func (l *Logger) SetFallback(w io.Writer, failures int, retry time.Duration) {
+	if failures < 1 {
+		failures = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fallback = w
+	l.maxFailures = failures
+	l.retry = retry
+	l.failures = 0
+}
+
+// emit passes r to h, or writes the formatted record buf, made of the
+// lines ending at ends, to the output, and writes it to the fallback if
+// that fails or if the circuit breaker is open. buf is nil if h is not.
+// It returns the errors of the primary output and of the fallback, and
+// whether the record is lost. l.mu must be held.
+func (l *Logger) emit(h Handler, buf *[]byte, ends []int, flag int, prefix string, r *Record) (lost bool, perr, ferr error) {
+	open := l.fallback != nil && l.failures >= l.maxFailures
+	if !open || !r.Time.Before(l.retryAt) {
+		if h != nil {
+			perr = h.Handle(*r)
+			l.counters.wrote(0, perr)
+		} else {
+			var n int
+			n, perr = writeLines(l.out, *buf, ends)
+			l.counters.wrote(n, perr)
+		}
+		if perr == nil {
+			l.failures = 0
+			l.counters.emit(r.Level)
+			return false, nil, nil
+		}
+		if l.fallback == nil {
+			l.counters.lose()
+			return true, perr, nil
+		}
+		if l.failures++; l.failures >= l.maxFailures {
+			l.retryAt = r.Time.Add(l.retry)
+		}
+	}
+	if buf == nil {
+		buf = getBuffer()
+		defer putBuffer(buf)
+		ends = formatLimited(buf, flag, prefix, r, l.maxLine, l.splitLine)
+	}
+	n, ferr := writeLines(l.fallback, *buf, ends)
+	l.counters.wrote(n, ferr)
+	if ferr != nil {
+		l.counters.lose()
+		return true, perr, ferr
+	}
+	l.counters.emit(r.Level)
+	return false, perr, nil
+}
+
+// handleError passes err to eh, or reports it if eh is nil.
+func handleError(eh ErrorHandler, err error, r Record) {
+	if eh != nil {
+		eh(err, r)
+		return
+	}
+	reportError(err)
+}
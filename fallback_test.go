@@ -0,0 +1,98 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyWriter fails while fail is set.
+type flakyWriter struct {
+	fail bool
+	bytes.Buffer
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		return 0, errors.New("broken pipe")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestErrorHandler(t *testing.T) {
+	var errs []string
+	l := New(failWriter{}, 0, LevelAll)
+	l.SetErrorHandler(func(err error, r Record) {
+		errs = append(errs, err.Error()+": "+r.Message)
+	})
+	if err := l.Output(1, "a", prefixInfo); err == nil {
+		t.Error("expected the write error")
+	}
+	l.Error("b")
+	if len(errs) != 2 || errs[0] != "disk full: a" || errs[1] != "disk full: b" {
+		t.Errorf("unexpected errors %q", errs)
+	}
+	if s := l.Stats()[0]; s.Errors != 2 || s.Lost != 2 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	var errs int
+	now := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	primary, fallback := &flakyWriter{}, &flakyWriter{}
+	l := New(primary, 0, LevelAll)
+	l.SetClock(func() time.Time { return now })
+	l.SetErrorHandler(func(error, Record) { errs++ })
+	l.SetFallback(fallback, 2, time.Minute)
+
+	primary.fail = true
+	l.Info("failure")
+	if err := l.Output(1, "second failure", prefixInfo); err != nil {
+		t.Errorf("Output = %v for a record written to the fallback", err)
+	}
+	primary.fail = false
+	l.Info("breaker open")
+	now = now.Add(time.Minute)
+	l.Info("retried")
+	l.Info("closed")
+
+	if want := "INFO retried\nINFO closed\n"; primary.String() != want {
+		t.Errorf("primary = %q, want %q", primary.String(), want)
+	}
+	if want := "INFO failure\nINFO second failure\nINFO breaker open\n"; fallback.String() != want {
+		t.Errorf("fallback = %q, want %q", fallback.String(), want)
+	}
+	if s := l.Stats()[0]; errs != 2 || s.Errors != 2 || s.Lost != 0 || s.Emitted[LevelInfo] != 5 {
+		t.Errorf("unexpected stats %+v and %d errors", s, errs)
+	}
+
+	primary.fail, fallback.fail = true, true
+	l.Info("x")
+	if err := l.Output(1, "y", prefixInfo); err == nil || err.Error() != "broken pipe" {
+		t.Errorf("Output = %v, want the fallback error", err)
+	}
+	if s := l.Stats()[0]; s.Lost != 2 || s.Emitted[LevelInfo] != 5 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestFallbackHandler(t *testing.T) {
+	var fallback bytes.Buffer
+	l := New(nil, 0, LevelAll)
+	l.SetHandler(HandlerFunc(func(r Record) error {
+		return errors.New("unavailable")
+	}))
+	l.SetErrorHandler(func(error, Record) {})
+	l.SetFallback(&fallback, 1, time.Hour)
+	l.With("k", "v").Warning("a")
+	l.Warning("b")
+	if want := "WARN a k=v\nWARN b\n"; fallback.String() != want {
+		t.Errorf("fallback = %q, want %q", fallback.String(), want)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// A Formatter appends the representation of a record, including its
+// trailing newline, to buf and returns the extended buffer.
+type Formatter interface {
+	Format(buf []byte, r *Record) []byte
+}
+
+// TextFormatter formats records as a Logger with the given flags does.
+type TextFormatter struct {
+	Flag int
+}
+
+// Format implements Formatter.
+func (f TextFormatter) Format(buf []byte, r *Record) []byte {
+	formatRecord(&buf, f.Flag, levelPrefix(r.Level), r)
+	return buf
+}
+
+// JSONFormatter formats records as JSON objects, one per line:
+//
+//	{"time":"2009-11-10T23:00:00Z","level":"error","msg":"failed","file":"/a/b/c/d.go","line":23,"user":"gopher"}
+//
+// The level is omitted for records without one, and the file and line if
+// they are unknown. Fields follow in order; values that cannot be
+// marshalled are written as strings, and errors as their message. Control
+// characters are escaped, so every record is a single line.
+type JSONFormatter struct{}
+
+// Format implements Formatter.
+func (JSONFormatter) Format(buf []byte, r *Record) []byte {
+	buf = append(buf, `{"time":"`...)
+	buf = r.Time.AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, '"')
+	if name := LevelName(r.Level); name != "" {
+		buf = append(buf, `,"level":"`...)
+		buf = append(buf, name...)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONString(buf, r.Message)
+	if r.File != "" {
+		buf = append(buf, `,"file":`...)
+		buf = appendJSONString(buf, r.File)
+		buf = append(buf, `,"line":`...)
+		buf = strconv.AppendInt(buf, int64(r.Line), 10)
+	}
+	for _, f := range r.Fields {
+		buf = append(buf, ',')
+		buf = appendJSONString(buf, f.Key)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, f.Value)
+	}
+	return append(buf, "}\n"...)
+}
+
+// LogfmtFormatter formats records as logfmt lines:
+//
+//	time=2009-11-10T23:00:00Z level=error msg=failed file=/a/b/c/d.go line=23 user=gopher
+//
+// The level is omitted for records without one, and the file and line if
+// they are unknown. Fields follow in order, quoted as by a Logger, with
+// their keys sanitized as by Lsanitize. Every record is a single line.
+type LogfmtFormatter struct{}
+
+// Format implements Formatter.
+func (LogfmtFormatter) Format(buf []byte, r *Record) []byte {
+	buf = append(buf, "time="...)
+	buf = r.Time.AppendFormat(buf, time.RFC3339Nano)
+	if name := LevelName(r.Level); name != "" {
+		buf = append(buf, " level="...)
+		buf = append(buf, name...)
+	}
+	buf = append(buf, " msg="...)
+	buf = appendValue(buf, r.Message)
+	if r.File != "" {
+		buf = append(buf, " file="...)
+		buf = appendValue(buf, r.File)
+		buf = append(buf, " line="...)
+		buf = strconv.AppendInt(buf, int64(r.Line), 10)
+	}
+	buf = appendFields(buf, r.Fields, Lsanitize)
+	return append(buf, '\n')
+}
+
+// LevelName returns the lower case name of the given level: "debug",
+// "info", "warning", "error" or "fatal", or "" if level is not exactly one
+// of the predefined levels.
+func LevelName(level int) string {
+	switch level {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarning:
+		return "warning"
+	case LevelError:
+		return "error"
+	case LevelFatal:
+		return "fatal"
+	}
+	return ""
+}
+
+func appendJSONString(buf []byte, s string) []byte {
+	b, _ := json.Marshal(s) // strings always marshal.
+	return appendJSON(buf, b)
+}
+
+func appendJSONValue(buf []byte, v interface{}) []byte {
+	if err, ok := v.(error); ok {
+		return appendJSONString(buf, err.Error())
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return appendJSONString(buf, fmtValue(v))
+	}
+	return appendJSON(buf, b)
+}
+
+// appendJSON appends the marshalled JSON b with the runes that json.Marshal
+// leaves and appendSanitized escapes, such as DEL and the C1 controls,
+// escaped. They can only appear in strings, where \u escapes are valid.
+func appendJSON(buf []byte, b []byte) []byte {
+	for i := 0; i < len(b); {
+		r, size := rune(b[i]), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRune(b[i:])
+		}
+		if escapedRune(r) {
+			buf = appendHexEscape(buf, 'u', uint32(r), 4)
+		} else {
+			buf = append(buf, b[i:i+size]...)
+		}
+		i += size
+	}
+	return buf
+}
@@ -0,0 +1,46 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJSONFormatter(t *testing.T) {
+	r := Record{
+		Time:    time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC),
+		Level:   LevelError,
+		Message: "failed \"x\"",
+		File:    "/a/b/c/d.go",
+		Line:    23,
+		Fields: []Field{
+			{Key: "user", Value: "gopher"},
+			{Key: "n", Value: 7},
+			{Key: "err", Value: errors.New("boom")},
+			{Key: "ch", Value: make(chan int)},
+		},
+	}
+	got := string(JSONFormatter{}.Format(nil, &r))
+	want := `{"time":"2009-11-10T23:00:00Z","level":"error","msg":"failed \"x\"","file":"/a/b/c/d.go","line":23,"user":"gopher","n":7,"err":"boom","ch":"` + fmtValue(r.Fields[3].Value) + `"}` + "\n"
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	r = Record{Time: r.Time, Message: "plain"}
+	got = string(JSONFormatter{}.Format(nil, &r))
+	if want := `{"time":"2009-11-10T23:00:00Z","msg":"plain"}` + "\n"; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestTextFormatter(t *testing.T) {
+	r := Record{Level: LevelInfo, Message: "hello", File: "/a/b/d.go", Line: 3, Fields: []Field{{Key: "k", Value: "v"}}}
+	got := string(TextFormatter{Flag: Lshortfile}.Format(nil, &r))
+	if want := "INFO d.go:3: hello k=v\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
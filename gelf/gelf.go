@@ -0,0 +1,312 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package gelf provides a log.Handler that sends records to Graylog in the
+// GELF 1.1 format, as compressed and chunked UDP datagrams or as null
+// delimited messages over TCP.
+//
+//	h, err := gelf.DialUDP("graylog:12201", nil)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer h.Close()
+//	logger.SetHandler(h)
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-gem/log"
+	"github.com/go-gem/log/syslog"
+)
+
+// An Encoder encodes records as GELF 1.1 messages.
+//
+// The level of a record is mapped to a syslog severity, its caller to the
+// _file and _line additional fields, and its fields to additional fields
+// whose names are prefixed with an underscore. Messages with several lines
+// are sent as full_message, with their first line as short_message.
+type Encoder struct {
+	Host string // defaults to os.Hostname
+}
+
+// Encode appends the GELF message for r to buf.
+func (e Encoder) Encode(buf []byte, r *log.Record) []byte {
+	host := e.Host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	buf = append(buf, `{"version":"1.1","host":`...)
+	buf = appendString(buf, host)
+	short := r.Message
+	if i := strings.IndexByte(short, '\n'); i >= 0 {
+		short = short[:i]
+		buf = append(buf, `,"full_message":`...)
+		buf = appendString(buf, r.Message)
+	}
+	if short == "" {
+		short = "-" // short_message must not be empty.
+	}
+	buf = append(buf, `,"short_message":`...)
+	buf = appendString(buf, short)
+	buf = append(buf, `,"timestamp":`...)
+	buf = strconv.AppendFloat(buf, float64(r.Time.UnixNano()/1e3)/1e6, 'f', 6, 64)
+	buf = append(buf, `,"level":`...)
+	buf = strconv.AppendInt(buf, int64(syslog.LevelSeverity(r.Level)), 10)
+	if r.File != "" {
+		buf = append(buf, `,"_file":`...)
+		buf = appendString(buf, r.File)
+		buf = append(buf, `,"_line":`...)
+		buf = strconv.AppendInt(buf, int64(r.Line), 10)
+	}
+	for _, f := range r.Fields {
+		buf = append(buf, ',')
+		buf = appendString(buf, FieldName(f.Key))
+		buf = append(buf, ':')
+		buf = appendValue(buf, f.Value)
+	}
+	return append(buf, '}')
+}
+
+// FieldName returns the name of the additional field for the given key:
+// the key prefixed with an underscore, with characters other than letters,
+// digits, '_', '.' and '-' replaced by '_'. The reserved name _id becomes
+// _id_.
+func FieldName(key string) string {
+	name := make([]byte, 0, len(key)+1)
+	name = append(name, '_')
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '_', c == '.', c == '-':
+			name = append(name, c)
+		default:
+			name = append(name, '_')
+		}
+	}
+	if string(name) == "_id" {
+		return "_id_"
+	}
+	return string(name)
+}
+
+func appendString(buf []byte, s string) []byte {
+	b, _ := json.Marshal(s) // strings always marshal.
+	return append(buf, b...)
+}
+
+// appendValue appends v as a JSON number if it is one, and as a string
+// otherwise, since additional fields may only be strings or numbers.
+func appendValue(buf []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int8:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int16:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(buf, v, 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint8:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint16:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint32:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(buf, v, 10)
+	case float32:
+		return appendFloat(buf, float64(v), 32)
+	case float64:
+		return appendFloat(buf, v, 64)
+	}
+	return appendString(buf, fmt.Sprint(v))
+}
+
+func appendFloat(buf []byte, f float64, bits int) []byte {
+	b, err := json.Marshal(f)
+	if err != nil { // NaN and infinities
+		return appendString(buf, strconv.FormatFloat(f, 'g', -1, bits))
+	}
+	return append(buf, b...)
+}
+
+// A Compression is the compression of UDP messages.
+type Compression int
+
+// Compressions.
+const (
+	Gzip Compression = iota
+	Zlib
+	None
+)
+
+// Options configure a Handler.
+type Options struct {
+	Host        string      // defaults to os.Hostname
+	Compression Compression // of UDP messages
+	ChunkSize   int         // maximum UDP datagram size; default 1420
+}
+
+// maxChunks is the maximum number of chunks of a message.
+const maxChunks = 128
+
+// chunkHeaderSize is the size of the header of a chunk: the magic bytes,
+// the message ID, the sequence number and the sequence count.
+const chunkHeaderSize = 12
+
+// ErrTooLarge is returned when a message needs more than 128 chunks.
+var ErrTooLarge = errors.New("gelf: message too large")
+
+// ErrClosed is returned by Handle after Close.
+var ErrClosed = errors.New("gelf: handler is closed")
+
+// A Handler is a log.Handler that sends GELF messages to Graylog.
+// A Handler can be used simultaneously from multiple goroutines.
+type Handler struct {
+	mu      sync.Mutex
+	enc     Encoder
+	opts    Options
+	network string
+	addr    string
+	conn    net.Conn // nil after a failed TCP write, until redialed
+	closed  bool
+	buf     []byte
+	zbuf    bytes.Buffer
+}
+
+// DialUDP returns a Handler sending compressed, and if needed chunked,
+// messages to addr over UDP. opts may be nil.
+func DialUDP(addr string, opts *Options) (*Handler, error) {
+	return dial("udp", addr, opts)
+}
+
+// DialTCP returns a Handler sending null delimited messages to addr over
+// TCP. It reconnects if a write fails. opts may be nil.
+func DialTCP(addr string, opts *Options) (*Handler, error) {
+	return dial("tcp", addr, opts)
+}
+
+func dial(network, addr string, opts *Options) (*Handler, error) {
+	h := &Handler{network: network, addr: addr}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Host == "" {
+		h.opts.Host, _ = os.Hostname()
+	}
+	if h.opts.ChunkSize <= chunkHeaderSize {
+		h.opts.ChunkSize = 1420
+	}
+	h.enc = Encoder{Host: h.opts.Host}
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	h.conn = conn
+	return h, nil
+}
+
+// Handle implements log.Handler.
+func (h *Handler) Handle(r log.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrClosed
+	}
+	h.buf = h.enc.Encode(h.buf[:0], &r)
+	if h.network == "udp" {
+		return h.writeUDP(h.buf)
+	}
+	h.buf = append(h.buf, 0)
+	if h.conn != nil {
+		if _, err := h.conn.Write(h.buf); err == nil {
+			return nil
+		}
+		h.conn.Close()
+		h.conn = nil
+	}
+	conn, err := net.Dial(h.network, h.addr)
+	if err != nil {
+		return err
+	}
+	h.conn = conn
+	_, err = h.conn.Write(h.buf)
+	return err
+}
+
+// writeUDP compresses msg and writes it in as many chunks as needed.
+func (h *Handler) writeUDP(msg []byte) error {
+	h.zbuf.Reset()
+	var w io.WriteCloser
+	switch h.opts.Compression {
+	case Gzip:
+		w = gzip.NewWriter(&h.zbuf)
+	case Zlib:
+		w = zlib.NewWriter(&h.zbuf)
+	}
+	if w != nil {
+		w.Write(msg)
+		w.Close()
+		msg = h.zbuf.Bytes()
+	}
+	if len(msg) <= h.opts.ChunkSize {
+		_, err := h.conn.Write(msg)
+		return err
+	}
+	size := h.opts.ChunkSize - chunkHeaderSize
+	count := (len(msg) + size - 1) / size
+	if count > maxChunks {
+		return ErrTooLarge
+	}
+	chunk := make([]byte, 0, h.opts.ChunkSize)
+	chunk = append(chunk, 0x1e, 0x0f)
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return err
+	}
+	chunk = append(chunk, id[:]...)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk = append(chunk[:10], byte(i), byte(count))
+		chunk = append(chunk, msg[i*size:end]...)
+		if _, err := h.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection to Graylog. Handle then returns ErrClosed
+// rather than reconnecting.
+func (h *Handler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	if h.conn == nil {
+		return nil
+	}
+	err := h.conn.Close()
+	h.conn = nil
+	return err
+}
@@ -0,0 +1,206 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gem/log"
+)
+
+var testTime = time.Date(2009, 11, 10, 23, 0, 0, 123456000, time.UTC)
+
+func TestEncode(t *testing.T) {
+	r := log.Record{
+		Time:    testTime,
+		Level:   log.LevelWarning,
+		Message: "slow\nrequest",
+		File:    "/src/app/main.go",
+		Line:    42,
+		Fields:  []log.Field{{Key: "id", Value: 7}, {Key: "user name", Value: "gopher"}, {Key: "ratio", Value: 0.5}},
+	}
+	got := string(Encoder{Host: "host"}.Encode(nil, &r))
+	want := `{"version":"1.1","host":"host","full_message":"slow\nrequest","short_message":"slow",` +
+		`"timestamp":1257894000.123456,"level":4,"_file":"/src/app/main.go","_line":42,` +
+		`"_id_":7,"_user_name":"gopher","_ratio":0.5}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+// reassemble reads datagrams from pc until it holds a whole message, and
+// returns it decompressed.
+func reassemble(t *testing.T, pc net.PacketConn) []byte {
+	chunks := make(map[byte][]byte)
+	b := make([]byte, 65536)
+	for {
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := append([]byte(nil), b[:n]...)
+		if len(msg) > 2 && msg[0] == 0x1e && msg[1] == 0x0f {
+			seq, count := msg[10], msg[11]
+			chunks[seq] = msg[12:]
+			if len(chunks) < int(count) {
+				continue
+			}
+			msg = nil
+			for i := byte(0); i < count; i++ {
+				msg = append(msg, chunks[i]...)
+			}
+		}
+		var r io.Reader = bytes.NewReader(msg)
+		switch {
+		case msg[0] == 0x1f && msg[1] == 0x8b:
+			if r, err = gzip.NewReader(r); err != nil {
+				t.Fatal(err)
+			}
+		case msg[0] == 0x78:
+			if r, err = zlib.NewReader(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		msg, err = ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+}
+
+func TestUDP(t *testing.T) {
+	for _, compression := range []Compression{Gzip, Zlib, None} {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Skip(err)
+		}
+		h, err := DialUDP(pc.LocalAddr().String(), &Options{Host: "host", Compression: compression, ChunkSize: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := log.New(nil, log.Lshortfile, log.LevelAll)
+		l.SetHandler(h)
+		for _, message := range []string{"short", strings.Repeat("long message ", 200)} {
+			l.With("user", "gopher").Error(message)
+			var m map[string]interface{}
+			if err := json.Unmarshal(reassemble(t, pc), &m); err != nil {
+				t.Fatal(err)
+			}
+			if m["short_message"] != message || m["level"] != 3.0 || m["_user"] != "gopher" || m["host"] != "host" {
+				t.Errorf("unexpected message %v", m)
+			}
+			if !strings.HasSuffix(m["_file"].(string), "gelf_test.go") {
+				t.Errorf("unexpected _file %v", m["_file"])
+			}
+		}
+		h.Close()
+		pc.Close()
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer pc.Close()
+	h, err := DialUDP(pc.LocalAddr().String(), &Options{Compression: None, ChunkSize: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if err := h.Handle(log.Record{Message: strings.Repeat("x", 2000)}); err != ErrTooLarge {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	messages := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			msg, err := r.ReadString(0)
+			if err != nil {
+				return
+			}
+			messages <- msg
+		}
+	}()
+	h, err := DialTCP(ln.Addr().String(), &Options{Host: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	for _, message := range []string{"one", "two"} {
+		if err := h.Handle(log.Record{Time: testTime, Level: log.LevelInfo, Message: message}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, message := range []string{"one", "two"} {
+		want := `{"version":"1.1","host":"host","short_message":"` + message + `","timestamp":1257894000.123456,"level":6}` + "\x00"
+		select {
+		case got := <-messages:
+			if got != want {
+				t.Errorf("got %q; want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestHandleAfterClose(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer pc.Close()
+	tcp, err := DialTCP(ln.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	udp, err := DialUDP(pc.LocalAddr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []*Handler{tcp, udp} {
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Handle(log.Record{Message: "late"}); err != ErrClosed {
+			t.Errorf("%s: expected ErrClosed, got %v", h.network, err)
+		}
+		if h.conn != nil {
+			t.Errorf("%s: redialed after Close", h.network)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// writerHandler writes formatted records to an io.Writer.
+type writerHandler struct {
+	w io.Writer
+	f Formatter
+}
+
+// NewWriterHandler returns a Handler that writes records to w as formatted
+// by f, one Write per record.
+func NewWriterHandler(w io.Writer, f Formatter) Handler {
+	return &writerHandler{w: w, f: f}
+}
+
+// NewTextHandler returns a Handler that writes records to w in the same
+// format as a Logger with the given flags, one Write per record. Caller
+// information is only available if the flags of the Logger request it.
+func NewTextHandler(w io.Writer, flag int) Handler {
+	return NewWriterHandler(w, TextFormatter{Flag: flag})
+}
+
+func (h *writerHandler) Handle(r Record) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	*buf = h.f.Format(*buf, &r)
+	_, err := h.w.Write(*buf)
+	return err
+}
+
+// FlightRecorderKey is the key of the field added to the records replayed
+// by a FlightRecorder.
+const FlightRecorderKey = "flight"
+
+// A FlightRecorder is a Handler that keeps recent records which are not
+// otherwise logged, and replays them when something goes wrong.
+//
+// Records at one of its levels, and records without a level, are passed
+// to the underlying Handler immediately; all other records are kept in
+// memory. When a record at or above the trigger level arrives, the kept
+// records are passed to the underlying Handler first, oldest first, with
+// the field flight=replay added, followed by the triggering record.
+//
+// Replay passes the kept records on demand. Flush and Close, which
+// Logger.Flush and Logger.Close call, do not: a normal shutdown discards
+// the kept records unless SetReplayOnClose is set.
+//
+// Since filtering happens in the FlightRecorder, the Logger using it
+// should have all levels enabled:
+//
+//	l := log.New(nil, log.LstdFlags, log.LevelAll)
+//	l.SetHandler(log.NewFlightRecorder(log.NewTextHandler(os.Stderr, log.LstdFlags),
+//		log.LevelInfo|log.LevelWarning|log.LevelError|log.LevelFatal, log.LevelError, 100, time.Minute))
+type FlightRecorder struct {
+	mu      sync.Mutex
+	h       Handler
+	levels  int
+	trigger int
+	window  time.Duration
+	ring    []Record // ring buffer of kept records
+	start   int      // index of the oldest record in ring
+	n       int      // number of records in ring
+	replay  bool     // replay on Close
+}
+
+// NewFlightRecorder returns a FlightRecorder passing records to h.
+// It keeps at most size records, and if window is positive, only those
+// logged at most window before the latest record.
+func NewFlightRecorder(h Handler, levels, trigger, size int, window time.Duration) *FlightRecorder {
+	if size < 1 {
+		size = 1
+	}
+	return &FlightRecorder{
+		h:       h,
+		levels:  levels,
+		trigger: trigger,
+		window:  window,
+		ring:    make([]Record, size),
+	}
+}
+
+// Handle implements Handler.
+func (f *FlightRecorder) Handle(r Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Level != 0 && r.Level >= f.trigger {
+		err := f.flush(r.Time)
+		if e := f.h.Handle(r); err == nil {
+			err = e
+		}
+		return err
+	}
+	if r.Level == 0 || r.Level&f.levels != 0 {
+		return f.h.Handle(r)
+	}
+	f.expire(r.Time)
+	if f.n == len(f.ring) {
+		f.ring[f.start] = Record{}
+		f.start = (f.start + 1) % len(f.ring)
+		f.n--
+	}
+	f.ring[(f.start+f.n)%len(f.ring)] = r
+	f.n++
+	return nil
+}
+
+// Replay passes the kept records as if a record at the trigger level had
+// been logged, without logging one. Records older than the window before
+// the latest kept record are dropped; the times of records come from the
+// clock of their Logger, so Replay never reads the wall clock.
+func (f *FlightRecorder) Replay() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.flush(f.latest())
+}
+
+// latest returns the time of the latest kept record. f.mu must be held.
+func (f *FlightRecorder) latest() time.Time {
+	if f.n == 0 {
+		return time.Time{}
+	}
+	return f.ring[(f.start+f.n-1)%len(f.ring)].Time
+}
+
+// SetReplayOnClose sets whether Close replays the kept records rather
+// than discard them.
+func (f *FlightRecorder) SetReplayOnClose(replay bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.replay = replay
+}
+
+// Flush flushes the underlying Handler if it has a Flush() error method.
+// The kept records stay kept.
+func (f *FlightRecorder) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return flushHandler(f.h)
+}
+
+// Close discards the kept records, or replays them if SetReplayOnClose is
+// set, then closes the underlying Handler if it is an io.Closer.
+func (f *FlightRecorder) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var err error
+	if f.replay {
+		err = f.flush(f.latest())
+	}
+	for ; f.n > 0; f.n-- {
+		f.ring[f.start] = Record{}
+		f.start = (f.start + 1) % len(f.ring)
+	}
+	f.start = 0
+	if c, ok := f.h.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// flushHandler flushes h if it has a Flush() error method.
+func flushHandler(h Handler) error {
+	if fl, ok := h.(interface {
+		Flush() error
+	}); ok {
+		return fl.Flush()
+	}
+	return nil
+}
+
+// Len returns the number of records currently kept.
+func (f *FlightRecorder) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.n
+}
+
+// expire drops the records older than the window before now.
+func (f *FlightRecorder) expire(now time.Time) {
+	if f.window <= 0 {
+		return
+	}
+	for f.n > 0 && now.Sub(f.ring[f.start].Time) > f.window {
+		f.ring[f.start] = Record{}
+		f.start = (f.start + 1) % len(f.ring)
+		f.n--
+	}
+}
+
+func (f *FlightRecorder) flush(now time.Time) error {
+	f.expire(now)
+	var err error
+	for ; f.n > 0; f.n-- {
+		r := f.ring[f.start]
+		f.ring[f.start] = Record{}
+		f.start = (f.start + 1) % len(f.ring)
+		fields := make([]Field, 0, len(r.Fields)+1)
+		fields = append(fields, r.Fields...)
+		r.Fields = append(fields, Field{Key: FlightRecorderKey, Value: "replay"})
+		if e := f.h.Handle(r); err == nil {
+			err = e
+		}
+	}
+	f.start = 0
+	return err
+}
@@ -0,0 +1,89 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTextHandler(t *testing.T) {
+	var b bytes.Buffer
+	l := New(nil, 0, LevelAll)
+	l.SetHandler(NewTextHandler(&b, 0))
+	l.With("k", "v").Warningln("careful")
+	l.Print("plain")
+	if expect := "WARN careful k=v\nplain\n"; b.String() != expect {
+		t.Errorf("log output should match %q is %q", expect, b.String())
+	}
+}
+
+func TestFlightRecorder(t *testing.T) {
+	var b bytes.Buffer
+	now := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	l := New(nil, 0, LevelAll)
+	l.SetClock(func() time.Time { return now })
+	f := NewFlightRecorder(NewTextHandler(&b, 0), LevelInfo|LevelError, LevelError, 2, time.Minute)
+	l.SetHandler(f)
+
+	l.Debug("dropped")
+	l.Info("info")
+	l.Debug("too old")
+	now = now.Add(2 * time.Minute)
+	l.Warning("warning")
+	l.Debug("debug")
+	if f.Len() != 2 {
+		t.Errorf("expected 2 kept records, got %d", f.Len())
+	}
+	l.Error("error")
+	l.Error("again")
+
+	expect := "INFO info\n" +
+		"WARN warning flight=replay\n" +
+		"DEBU debug flight=replay\n" +
+		"ERRO error\n" +
+		"ERRO again\n"
+	if b.String() != expect {
+		t.Errorf("log output should match %q is %q", expect, b.String())
+	}
+}
+
+func TestFlightRecorderReplayClose(t *testing.T) {
+	var b bytes.Buffer
+	now := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	l := New(nil, 0, LevelAll)
+	l.SetClock(func() time.Time { return now })
+	f := NewFlightRecorder(NewTextHandler(&b, 0), LevelInfo, LevelError, 10, time.Minute)
+	l.SetHandler(f)
+
+	l.Debug("old")
+	now = now.Add(2 * time.Minute)
+	l.Debug("kept")
+	if err := l.Flush(); err != nil || b.Len() != 0 || f.Len() != 1 {
+		t.Fatalf("Flush: %v, output %q, %d kept", err, b.String(), f.Len())
+	}
+	// the window is measured on the clock of the records, not time.Now.
+	if err := f.Replay(); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "DEBU kept flight=replay\n"; b.String() != expect {
+		t.Errorf("replay output should match %q is %q", expect, b.String())
+	}
+
+	b.Reset()
+	l.Debug("discarded")
+	if err := l.Close(); err != nil || b.Len() != 0 || f.Len() != 0 {
+		t.Errorf("Close: %v, output %q, %d kept", err, b.String(), f.Len())
+	}
+	f.SetReplayOnClose(true)
+	l.Debug("replayed")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "DEBU replayed flight=replay\n"; b.String() != expect {
+		t.Errorf("close output should match %q is %q", expect, b.String())
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"os"
+)
+
+// A hook is a function added by AddHook.
+type hook struct {
+	levels int
+	fn     func(Record) error
+}
+
+// fires reports whether h is called for records of the given level.
+// Records without a level, written by Print and Panic, fire only the hooks
+// of LevelAll.
+func (h hook) fires(level int) bool {
+	if level == 0 {
+		return h.levels&LevelAll == LevelAll
+	}
+	return h.levels&level != 0
+}
+
+// AddHook adds a function called with every record of the given levels
+// that is logged by l or by the loggers sharing its output. Hooks are
+// called after the level filtering and the redaction of the record, and
+// before it is passed to the Handler or written; every hook is called, in
+// the order they were added, even if a previous one failed. Their errors
+// are passed to the ErrorHandler of the Logger.
+//
+// Hooks are called without holding the Logger's lock, and so may be
+// called concurrently and may log. They must not modify the fields of
+// the record.
+func (l *Logger) AddHook(levels int, fn func(Record) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	hooks := make([]hook, len(l.hooks), len(l.hooks)+1)
+	copy(hooks, l.hooks)
+	l.hooks = append(hooks, hook{levels: levels, fn: fn})
+}
+
+// runHooks calls the hooks that fire for r.
+func runHooks(hooks []hook, r Record, eh ErrorHandler) {
+	for _, h := range hooks {
+		if !h.fires(r.Level) {
+			continue
+		}
+		if err := h.fn(r); err != nil {
+			handleError(eh, err, r)
+		}
+	}
+}
+
+// reportError reports the errors that cannot be returned to the caller.
+// It is a variable so that tests can capture them.
+var reportError = func(err error) {
+	fmt.Fprintf(os.Stderr, "log: %v\n", err)
+}
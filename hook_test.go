@@ -0,0 +1,78 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// callWriter records the writes in calls.
+type callWriter struct {
+	calls *[]string
+}
+
+func (w callWriter) Write(p []byte) (int, error) {
+	*w.calls = append(*w.calls, "write "+string(p))
+	return len(p), nil
+}
+
+func TestHooks(t *testing.T) {
+	var calls, reported []string
+	defer func(f func(error)) { reportError = f }(reportError)
+	reportError = func(err error) {
+		reported = append(reported, err.Error())
+	}
+
+	l := New(callWriter{&calls}, 0, LevelAll&^LevelDebug)
+	l.SetRedactor(NewRedactor([]string{"password"}))
+	l.AddHook(LevelError, func(r Record) error {
+		calls = append(calls, "first "+r.Message)
+		return errors.New("first failed")
+	})
+	l.AddHook(LevelAll, func(r Record) error {
+		calls = append(calls, "second "+r.Message+" "+fmtValue(r.Fields[0].Value))
+		return nil
+	})
+	l.AddHook(LevelDebug|LevelInfo, func(r Record) error {
+		calls = append(calls, "third "+r.Message)
+		return nil
+	})
+	l = l.With("password", "hunter2")
+	l.Debug("ignored")
+	l.Error("a")
+	l.Info("b")
+	l.Print("c")
+
+	want := []string{
+		"first a", "second a [REDACTED]", "write ERRO a password=[REDACTED]\n",
+		"second b [REDACTED]", "third b", "write INFO b password=[REDACTED]\n",
+		"second c [REDACTED]", "write c password=[REDACTED]\n",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if !reflect.DeepEqual(reported, []string{"first failed"}) {
+		t.Errorf("reported = %q", reported)
+	}
+}
+
+func TestHookLogs(t *testing.T) {
+	var calls []string
+	l := New(callWriter{&calls}, 0, LevelAll)
+	l.AddHook(LevelError, func(r Record) error {
+		l.Info("alerted")
+		return nil
+	})
+	l.SetHandler(HandlerFunc(func(r Record) error {
+		calls = append(calls, r.Message)
+		return nil
+	}))
+	l.Error("boom")
+	if want := []string{"alerted", "boom"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
@@ -0,0 +1,15 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.8
+// +build go1.8
+
+package httplog
+
+import "net/http"
+
+// isAbort reports whether v is http.ErrAbortHandler.
+func isAbort(v interface{}) bool {
+	return v == http.ErrAbortHandler
+}
@@ -0,0 +1,13 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.8
+// +build !go1.8
+
+package httplog
+
+// isAbort reports false: http.ErrAbortHandler was added in Go 1.8.
+func isAbort(v interface{}) bool {
+	return false
+}
@@ -0,0 +1,31 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.8
+// +build go1.8
+
+package httplog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-gem/log"
+	"github.com/go-gem/log/logtest"
+)
+
+func TestRecovererAbort(t *testing.T) {
+	l, rec := logtest.New(log.LevelAll)
+	h := Recoverer(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler, got %v", v)
+		}
+		logtest.AssertLen(t, rec, 0)
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,225 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package httplog provides HTTP middleware that logs every request.
+//
+//	http.ListenAndServe(":8080", httplog.Handler(logger, mux, nil))
+//
+// Requests are logged at LevelError if the response status is 5xx, at
+// LevelWarning if it is 4xx, and at LevelInfo otherwise. The handler can
+// get a Logger for the request, which carries its ID and trace, with
+// log.FromContext(r.Context()).
+package httplog
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-gem/log"
+)
+
+// A Format is the layout of access log records.
+type Format int
+
+// Formats.
+const (
+	// Fields logs the message "METHOD PATH STATUS" with the fields method,
+	// path, status, bytes, duration, remote_addr, user_agent and request_id.
+	Fields Format = iota
+	// Common logs the Apache Common Log Format as message.
+	Common
+	// Combined logs the Apache Combined Log Format as message.
+	Combined
+)
+
+// DefaultRequestIDHeader is the header holding the ID of a request unless
+// Options.RequestIDHeader is set.
+const DefaultRequestIDHeader = "X-Request-Id"
+
+// RequestIDKey is the key of the request ID field.
+const RequestIDKey = "request_id"
+
+// Options configure Handler. The zero value logs fields.
+type Options struct {
+	Format Format
+	// RequestIDHeader is the header holding the request ID. If a request
+	// does not have one, a random ID is generated. The ID is always
+	// echoed in the response header.
+	RequestIDHeader string
+}
+
+// Handler returns an http.Handler that serves requests with next and logs
+// them to l. opts may be nil.
+//
+// The context of each request carries a Logger derived from l, obtained
+// with log.FromContext, which attaches the request ID and, if the request
+// has a W3C traceparent header, its trace and span IDs.
+func Handler(l *log.Logger, next http.Handler, opts *Options) http.Handler {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.RequestIDHeader == "" {
+		o.RequestIDHeader = DefaultRequestIDHeader
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(o.RequestIDHeader)
+		if id == "" {
+			id = newID()
+		}
+		w.Header().Set(o.RequestIDHeader, id)
+
+		ctx := r.Context()
+		if traceparent := r.Header.Get("Traceparent"); traceparent != "" {
+			ctx = log.ContextWithTraceparent(ctx, traceparent)
+		}
+		rl := l.WithContext(ctx).With(RequestIDKey, id)
+		ctx = context.WithValue(log.NewContext(ctx, rl), requestKey{}, true)
+
+		rw := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw.wrap(), r.WithContext(ctx))
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+
+		level := log.LevelInfo
+		switch {
+		case rw.status >= 500:
+			level = log.LevelError
+		case rw.status >= 400:
+			level = log.LevelWarning
+		}
+		switch o.Format {
+		case Common, Combined:
+			rl.V(level).Print(apacheLine(r, rw, start, o.Format == Combined))
+		default:
+			rl.With(
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", rw.status,
+				"bytes", rw.bytes,
+				"duration", time.Since(start),
+				"remote_addr", r.RemoteAddr,
+				"user_agent", r.UserAgent(),
+			).V(level).Printf("%s %s %d", r.Method, r.URL.Path, rw.status)
+		}
+	})
+}
+
+// requestKey marks the contexts of requests served by Handler.
+type requestKey struct{}
+
+// apacheLine returns the Common or Combined Log Format line of a request.
+func apacheLine(r *http.Request, rw *responseWriter, start time.Time, combined bool) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user := "-"
+	if r.URL.User != nil {
+		if name := r.URL.User.Username(); name != "" {
+			user = name
+		}
+	} else if name, _, ok := r.BasicAuth(); ok && name != "" {
+		user = name
+	}
+	size := "-"
+	if rw.bytes > 0 {
+		size = strconv.FormatInt(rw.bytes, 10)
+	}
+	line := fmt.Sprintf("%s - %s [%s] %q %d %s",
+		dash(host), user, start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method+" "+r.RequestURI+" "+r.Proto, rw.status, size)
+	if combined {
+		line += fmt.Sprintf(" %q %q", r.Referer(), r.UserAgent())
+	}
+	return line
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// newID returns a random request ID of 16 hexadecimal digits.
+func newID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// responseWriter records the status and size of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// wrap returns w as an http.ResponseWriter that also implements
+// http.Flusher and http.Hijacker if, and only if, the underlying
+// ResponseWriter does, so that handlers testing for them are not misled.
+func (w *responseWriter) wrap() http.ResponseWriter {
+	_, flusher := w.ResponseWriter.(http.Flusher)
+	_, hijacker := w.ResponseWriter.(http.Hijacker)
+	switch {
+	case flusher && hijacker:
+		return flushHijackWriter{w}
+	case flusher:
+		return flushWriter{w}
+	case hijacker:
+		return hijackWriter{w}
+	}
+	return w
+}
+
+func (w *responseWriter) flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *responseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type flushWriter struct{ *responseWriter }
+
+func (w flushWriter) Flush() { w.flush() }
+
+type hijackWriter struct{ *responseWriter }
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+type flushHijackWriter struct{ *responseWriter }
+
+func (w flushHijackWriter) Flush() { w.flush() }
+
+func (w flushHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
@@ -0,0 +1,177 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httplog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/go-gem/log"
+	"github.com/go-gem/log/logtest"
+)
+
+func fields(r log.Record) map[string]interface{} {
+	m := make(map[string]interface{})
+	for _, f := range r.Fields {
+		m[f.Key] = f.Value
+	}
+	return m
+}
+
+func TestLevels(t *testing.T) {
+	tests := []struct {
+		status int
+		level  int
+	}{
+		{http.StatusOK, log.LevelInfo},
+		{http.StatusFound, log.LevelInfo},
+		{http.StatusNotFound, log.LevelWarning},
+		{http.StatusBadGateway, log.LevelError},
+	}
+	for _, test := range tests {
+		l, rec := logtest.New(log.LevelAll)
+		h := Handler(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+		}), nil)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if records := rec.Filter(test.level); len(records) != 1 {
+			t.Errorf("status %d: expected 1 record at level %d, got %d", test.status, test.level, rec.Len())
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	l, rec := logtest.New(log.LevelAll)
+	h := Handler(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.FromContext(r.Context()).Info("inside")
+		w.Write([]byte("hello"))
+	}), nil)
+	req := httptest.NewRequest("POST", "/path?q=1", nil)
+	req.Header.Set("User-Agent", "test")
+	req.Header.Set("X-Request-Id", "abc")
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Request-Id"); got != "abc" {
+		t.Errorf("expected the request ID to be echoed, got %q", got)
+	}
+	records := rec.Records()
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	inside := fields(records[0])
+	if inside[log.TraceIDKey] != "4bf92f3577b34da6a3ce929d0e0e4736" || inside[RequestIDKey] != "abc" {
+		t.Errorf("unexpected fields of the request logger %v", inside)
+	}
+	access := records[1]
+	if access.Level != log.LevelInfo || access.Message != "POST /path 200" {
+		t.Errorf("unexpected record %+v", access)
+	}
+	f := fields(access)
+	want := map[string]interface{}{
+		"method":      "POST",
+		"path":        "/path",
+		"status":      200,
+		"bytes":       int64(5),
+		"remote_addr": "192.0.2.1:1234",
+		"user_agent":  "test",
+		RequestIDKey:  "abc",
+	}
+	for k, v := range want {
+		if f[k] != v {
+			t.Errorf("%s = %v; want %v", k, f[k], v)
+		}
+	}
+	if _, ok := f["duration"].(time.Duration); !ok {
+		t.Errorf("unexpected duration %v", f["duration"])
+	}
+}
+
+func TestGeneratedRequestID(t *testing.T) {
+	l, _ := logtest.New(log.LevelAll)
+	h := Handler(l, http.NotFoundHandler(), &Options{RequestIDHeader: "X-Id"})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if id := w.Header().Get("X-Id"); !regexp.MustCompile(`^[0-9a-f]{16}$`).MatchString(id) {
+		t.Errorf("unexpected request ID %q", id)
+	}
+}
+
+func TestApacheFormats(t *testing.T) {
+	for _, test := range []struct {
+		format  Format
+		pattern string
+	}{
+		{Common, `^WARN 192\.0\.2\.1 - gopher \[\d\d/\w{3}/\d{4}:\d\d:\d\d:\d\d [-+]\d{4}\] "GET /x\?y=1 HTTP/1\.1" 404 19 request_id=\w+\n$`},
+		{Combined, `^WARN 192\.0\.2\.1 - gopher \[[^]]+\] "GET /x\?y=1 HTTP/1\.1" 404 19 "http://ref" "agent" request_id=\w+\n$`},
+	} {
+		var b bytes.Buffer
+		l := log.New(&b, 0, log.LevelAll)
+		h := Handler(l, http.NotFoundHandler(), &Options{Format: test.format})
+		req := httptest.NewRequest("GET", "/x?y=1", nil)
+		req.SetBasicAuth("gopher", "secret")
+		req.Header.Set("Referer", "http://ref")
+		req.Header.Set("User-Agent", "agent")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if !regexp.MustCompile(test.pattern).MatchString(b.String()) {
+			t.Errorf("log output should match %q is %q", test.pattern, b.String())
+		}
+	}
+}
+
+func TestDroppedStats(t *testing.T) {
+	for _, format := range []Format{Fields, Common} {
+		l, _ := logtest.New(log.LevelError)
+		h := Handler(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), &Options{Format: format})
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if s := l.Stats()[0]; s.Dropped[log.LevelInfo] != 1 {
+			t.Errorf("format %v: dropped %v, want 1 info record", format, s.Dropped)
+		}
+	}
+}
+
+// plainWriter is a ResponseWriter implementing no optional interface.
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+// hijackRecorder is a ResponseRecorder implementing http.Hijacker.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("not hijacked")
+}
+
+func TestOptionalInterfaces(t *testing.T) {
+	for _, test := range []struct {
+		w               http.ResponseWriter
+		flusher, hijack bool
+	}{
+		{plainWriter{httptest.NewRecorder()}, false, false},
+		{httptest.NewRecorder(), true, false},
+		{hijackRecorder{httptest.NewRecorder()}, true, true},
+	} {
+		l, _ := logtest.New(log.LevelAll)
+		var flusher, hijacker bool
+		h := Handler(l, Recoverer(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, flusher = w.(http.Flusher)
+			_, hijacker = w.(http.Hijacker)
+		})), nil)
+		h.ServeHTTP(test.w, httptest.NewRequest("GET", "/", nil))
+		if flusher != test.flusher || hijacker != test.hijack {
+			t.Errorf("%T: Flusher %v, Hijacker %v; want %v, %v", test.w, flusher, hijacker, test.flusher, test.hijack)
+		}
+	}
+}
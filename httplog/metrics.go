@@ -0,0 +1,22 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httplog
+
+import (
+	"net/http"
+
+	"github.com/go-gem/log"
+)
+
+// Metrics returns an http.Handler that serves the statistics of l in the
+// Prometheus text exposition format, for scraping:
+//
+//	http.Handle("/metrics", httplog.Metrics(logger))
+func Metrics(l *log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		l.WriteMetrics(w)
+	})
+}
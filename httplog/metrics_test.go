@@ -0,0 +1,27 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httplog
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-gem/log"
+)
+
+func TestMetrics(t *testing.T) {
+	l := log.New(ioutil.Discard, 0, log.LevelAll)
+	l.Error("boom")
+	w := httptest.NewRecorder()
+	Metrics(l).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if want := `log_records_total{logger="",level="error"} 1`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("body does not contain %q:\n%s", want, w.Body.String())
+	}
+}
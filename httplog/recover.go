@@ -0,0 +1,45 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httplog
+
+import (
+	"net/http"
+
+	"github.com/go-gem/log"
+)
+
+// Recoverer returns an http.Handler that serves requests with next and
+// recovers from its panics. A panic is logged at LevelError with its stack
+// trace, to the Logger of the request context if Handler set one and to l
+// otherwise, and answered with 500 Internal Server Error if the response
+// has not been started. Since Go 1.8, http.ErrAbortHandler is not logged,
+// and panics again, so that the server aborts the response.
+//
+// Wrap the Recoverer with Handler to log the request with its 500 status:
+//
+//	httplog.Handler(logger, httplog.Recoverer(logger, mux), nil)
+func Recoverer(l *log.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if isAbort(v) {
+				panic(v)
+			}
+			rl := l
+			if _, ok := r.Context().Value(requestKey{}).(bool); ok {
+				rl = log.FromContext(r.Context())
+			}
+			rl.LogPanic(log.LevelError, v)
+			if rw.status == 0 {
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(rw.wrap(), r)
+	})
+}
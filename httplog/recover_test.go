@@ -0,0 +1,38 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httplog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-gem/log"
+	"github.com/go-gem/log/logtest"
+)
+
+func TestRecoverer(t *testing.T) {
+	l, rec := logtest.New(log.LevelAll)
+	h := Handler(l, Recoverer(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})), nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+	records := rec.Filter(log.LevelError)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 error records, got %d", len(records))
+	}
+	panicked := fields(records[0])
+	if records[0].Message != "panic: boom" || panicked[log.StackKey] == nil || panicked[RequestIDKey] == nil {
+		t.Errorf("unexpected panic record %+v", records[0])
+	}
+	if !strings.HasSuffix(records[1].Message, " 500") {
+		t.Errorf("unexpected access record %+v", records[1])
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package journal provides a log.Handler that sends records to
+// systemd-journald using its native protocol, so that the caller and the
+// fields of a record become journal fields:
+//
+//	MESSAGE=disk full
+//	PRIORITY=3
+//	CODE_FILE=/src/app/main.go
+//	CODE_LINE=42
+//	CODE_FUNC=main.(*Server).handle
+//	SYSLOG_IDENTIFIER=app
+//	PATH=/var
+//
+// Entries too large for a datagram are passed to journald through a
+// sealed memfd, or an unlinked temporary file, on Linux.
+package journal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-gem/log"
+	"github.com/go-gem/log/syslog"
+)
+
+// SocketPath is the path of the journald socket for native messages.
+const SocketPath = "/run/systemd/journal/socket"
+
+// Available reports whether the journald socket exists.
+func Available() bool {
+	_, err := os.Stat(SocketPath)
+	return err == nil
+}
+
+// A Handler is a log.Handler that sends records to journald.
+// A Handler can be used simultaneously from multiple goroutines.
+type Handler struct {
+	mu         sync.Mutex
+	conn       *net.UnixConn // unconnected, so that it can pass files
+	addr       *net.UnixAddr
+	identifier string
+	buf        []byte
+}
+
+// Dial prepares to send to the journald socket at path, or at SocketPath if path
+// is empty. Records are sent with the given SYSLOG_IDENTIFIER, which
+// defaults to the base name of os.Args[0].
+func Dial(path, identifier string) (*Handler, error) {
+	if path == "" {
+		path = SocketPath
+	}
+	if identifier == "" {
+		identifier = filepath.Base(os.Args[0])
+	}
+	addr := &net.UnixAddr{Name: path, Net: "unixgram"}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{conn: conn, addr: addr, identifier: identifier}, nil
+}
+
+// Handle implements log.Handler.
+func (h *Handler) Handle(r log.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.buf = h.encode(h.buf[:0], &r)
+	_, err := h.conn.WriteToUnix(h.buf, h.addr)
+	if err != nil && isTooLarge(err) {
+		err = sendFile(h.conn, h.addr, h.buf)
+	}
+	return err
+}
+
+// Close closes the socket used to send to journald.
+func (h *Handler) Close() error {
+	return h.conn.Close()
+}
+
+// encode appends the native protocol encoding of r to buf.
+func (h *Handler) encode(buf []byte, r *log.Record) []byte {
+	buf = appendVar(buf, "MESSAGE", r.Message)
+	buf = appendVar(buf, "PRIORITY", strconv.Itoa(int(syslog.LevelSeverity(r.Level))))
+	if r.File != "" {
+		buf = appendVar(buf, "CODE_FILE", r.File)
+		buf = appendVar(buf, "CODE_LINE", strconv.Itoa(r.Line))
+	}
+	if r.Function != "" {
+		buf = appendVar(buf, "CODE_FUNC", r.Function)
+	} else if fn := runtime.FuncForPC(r.PC); r.PC != 0 && fn != nil {
+		buf = appendVar(buf, "CODE_FUNC", fn.Name())
+	}
+	buf = appendVar(buf, "SYSLOG_IDENTIFIER", h.identifier)
+	for _, f := range r.Fields {
+		buf = appendVar(buf, FieldName(f.Key), fmt.Sprint(f.Value))
+	}
+	return buf
+}
+
+// appendVar appends a single variable. Values containing a newline are
+// written in the binary form: the name, a newline, the length of the value
+// as a little-endian uint64, the value and a newline.
+func appendVar(buf []byte, name, value string) []byte {
+	buf = append(buf, name...)
+	if strings.IndexByte(value, '\n') < 0 {
+		buf = append(buf, '=')
+	} else {
+		var size [8]byte
+		binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+		buf = append(buf, '\n')
+		buf = append(buf, size[:]...)
+	}
+	buf = append(buf, value...)
+	return append(buf, '\n')
+}
+
+// FieldName converts key to a valid journal field name: upper case
+// letters, digits and underscores, not starting with an underscore or a
+// digit, at most 64 characters.
+func FieldName(key string) string {
+	name := make([]byte, 0, len(key))
+	for i := 0; i < len(key) && len(name) < 64; i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z':
+			name = append(name, c-'a'+'A')
+		case 'A' <= c && c <= 'Z':
+			name = append(name, c)
+		case '0' <= c && c <= '9' || c == '_':
+			if len(name) == 0 {
+				continue
+			}
+			name = append(name, c)
+		default:
+			if len(name) == 0 {
+				continue
+			}
+			name = append(name, '_')
+		}
+	}
+	if len(name) == 0 {
+		return "FIELD"
+	}
+	return string(name)
+}
@@ -0,0 +1,92 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package journal
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// memfdCreate is the number of the memfd_create system call, or 0 if it
+// is unknown for this architecture.
+var memfdCreate = map[string]uintptr{
+	"386":   356,
+	"amd64": 319,
+	"arm":   385,
+	"arm64": 279,
+}[runtime.GOARCH]
+
+const (
+	mfdAllowSealing = 0x2
+	fAddSeals       = 1033
+	sealAll         = 0x1 | 0x2 | 0x4 | 0x8 // F_SEAL_SEAL, SHRINK, GROW and WRITE
+)
+
+func isTooLarge(err error) bool {
+	if op, ok := err.(*net.OpError); ok {
+		err = op.Err
+	}
+	if sys, ok := err.(*os.SyscallError); ok {
+		err = sys.Err
+	}
+	return err == syscall.EMSGSIZE || err == syscall.ENOBUFS
+}
+
+// sendFile passes msg to journald as the content of a file descriptor.
+func sendFile(conn *net.UnixConn, addr *net.UnixAddr, msg []byte) error {
+	f, err := memfd(msg)
+	if err != nil {
+		f, err = tempFile(msg)
+		if err != nil {
+			return err
+		}
+	}
+	defer f.Close()
+	_, _, err = conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), addr)
+	return err
+}
+
+// memfd returns a sealed memfd holding msg.
+func memfd(msg []byte) (*os.File, error) {
+	if memfdCreate == 0 {
+		return nil, syscall.ENOSYS
+	}
+	name := []byte("journal\x00")
+	fd, _, errno := syscall.Syscall(memfdCreate, uintptr(unsafe.Pointer(&name[0])), mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	f := os.NewFile(fd, "journal")
+	if _, err := f.Write(msg); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, fAddSeals, sealAll); errno != 0 {
+		f.Close()
+		return nil, errno
+	}
+	return f, nil
+}
+
+// tempFile returns an unlinked temporary file holding msg.
+func tempFile(msg []byte) (*os.File, error) {
+	f, err := ioutil.TempFile("/dev/shm", "journal")
+	if err != nil {
+		f, err = ioutil.TempFile("", "journal")
+		if err != nil {
+			return nil, err
+		}
+	}
+	os.Remove(f.Name())
+	if _, err := f.Write(msg); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package journal
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/go-gem/log"
+)
+
+func TestOversized(t *testing.T) {
+	conn, path, done := listen(t)
+	defer done()
+	h, err := Dial(path, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	message := strings.Repeat("x", 1<<20)
+	if err := h.Handle(log.Record{Level: log.LevelInfo, Message: message}); err != nil {
+		t.Fatal(err)
+	}
+	oob := make([]byte, syscall.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(make([]byte, 16), oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("expected an empty datagram, got %d bytes", n)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("expected one control message, got %d (%v)", len(msgs), err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("expected one file descriptor, got %d (%v)", len(fds), err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "journal")
+	defer f.Close()
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars := decode(t, b); vars["MESSAGE"] != message || vars["PRIORITY"] != "6" {
+		t.Errorf("unexpected entry with %d bytes", len(b))
+	}
+}
+
+func TestTempFile(t *testing.T) {
+	f, err := tempFile([]byte("MESSAGE=hi\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be unlinked", f.Name())
+	}
+}
+
+func TestMemfd(t *testing.T) {
+	f, err := memfd([]byte("MESSAGE=hi\n"))
+	if err == syscall.ENOSYS || err == syscall.EPERM {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte("x")); err == nil {
+		t.Error("expected the memfd to be sealed against writes")
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package journal
+
+import (
+	"errors"
+	"net"
+)
+
+func isTooLarge(err error) bool {
+	return false
+}
+
+func sendFile(conn *net.UnixConn, addr *net.UnixAddr, msg []byte) error {
+	return errors.New("journal: entry too large")
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gem/log"
+)
+
+// decode parses a native protocol message.
+func decode(t *testing.T, b []byte) map[string]string {
+	vars := make(map[string]string)
+	for len(b) > 0 {
+		i := bytes.IndexAny(b, "=\n")
+		if i < 0 {
+			t.Fatalf("malformed message %q", b)
+		}
+		name := string(b[:i])
+		if b[i] == '=' {
+			j := bytes.IndexByte(b, '\n')
+			vars[name] = string(b[i+1 : j])
+			b = b[j+1:]
+			continue
+		}
+		size := int(binary.LittleEndian.Uint64(b[i+1 : i+9]))
+		vars[name] = string(b[i+9 : i+9+size])
+		b = b[i+9+size+1:]
+	}
+	return vars
+}
+
+// listen starts a stand-in for the journald socket.
+func listen(t *testing.T) (*net.UnixConn, string, func()) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Skip(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn, path, func() {
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	conn, path, done := listen(t)
+	defer done()
+	h, err := Dial(path, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	l := log.New(nil, log.Lshortfile, log.LevelAll)
+	l.SetHandler(h)
+	l.With("user-id", 7, "trace", "a\nb").Error("failed")
+
+	b := make([]byte, 4096)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := decode(t, b[:n])
+	want := map[string]string{
+		"MESSAGE":           "failed",
+		"PRIORITY":          "3",
+		"SYSLOG_IDENTIFIER": "app",
+		"USER_ID":           "7",
+		"TRACE":             "a\nb",
+	}
+	for name, value := range want {
+		if vars[name] != value {
+			t.Errorf("%s = %q; want %q", name, vars[name], value)
+		}
+	}
+	if filepath.Base(vars["CODE_FILE"]) != "journal_test.go" || vars["CODE_LINE"] == "" {
+		t.Errorf("unexpected caller %s:%s", vars["CODE_FILE"], vars["CODE_LINE"])
+	}
+	if !strings.HasSuffix(vars["CODE_FUNC"], ".TestHandler") {
+		t.Errorf("unexpected CODE_FUNC %q", vars["CODE_FUNC"])
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	tests := map[string]string{
+		"user":       "USER",
+		"user-id":    "USER_ID",
+		"_private":   "PRIVATE",
+		"9lives":     "LIVES",
+		"":           "FIELD",
+		"héllo":      "H__LLO",
+		"caMel_Case": "CAMEL_CASE",
+	}
+	for key, want := range tests {
+		if got := FieldName(key); got != want {
+			t.Errorf("FieldName(%q) = %q; want %q", key, got, want)
+		}
+	}
+	if got := FieldName(strings.Repeat("a", 100)); len(got) != 64 {
+		t.Errorf("expected 64 characters, got %d", len(got))
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// Markers of the records shortened by SetMaxLineLength.
+const (
+	TruncatedMarker    = "...(truncated)"
+	ContinuationMarker = "... "
+)
+
+// MinLineLength is the smallest limit accepted by SetMaxLineLength.
+const MinLineLength = 64
+
+// SetMaxLineLength limits the lines written to the output and to the
+// fallback to n bytes, including the newline, so that each of them fits
+// in a single atomic write, such as PIPE_BUF bytes to a pipe. Longer
+// records are truncated and end with TruncatedMarker, or, if split is
+// set, continue in records with the same header whose message starts with
+// ContinuationMarker; each is written by its own call to Write. Records
+// whose header leaves no room for continuations are truncated. Headers are
+// never cut: a record whose header alone is over the limit keeps it, and
+// only its message and fields are truncated.
+//
+// A non-positive n removes the limit; a smaller positive n than
+// MinLineLength means MinLineLength. Records passed to a Handler are not
+// limited. Embedded newlines, unless Lsingleline is set, are counted as
+// any other byte.
+func (l *Logger) SetMaxLineLength(n int, split bool) {
+	if n > 0 && n < MinLineLength {
+		n = MinLineLength
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxLine = n
+	l.splitLine = split
+}
+
+// formatLimited formats r into buf as formatRecord does, within the limit
+// of max bytes per line if max is positive. It returns the ends of the
+// lines if the record is split into several.
+func formatLimited(buf *[]byte, flag int, prefix string, r *Record, max int, split bool) []int {
+	header := formatRecord(buf, flag, prefix, r)
+	if max <= 0 || len(*buf) <= max {
+		return nil
+	}
+	b := *buf
+	room := max - header - len(ContinuationMarker) - 1
+	if !split || room < utf8.UTFMax {
+		// the header is never cut, even if it alone is over the limit.
+		cut := header
+		if end := max - len(TruncatedMarker) - 1; end > header {
+			if cut = runeStart(b, end); cut < header {
+				cut = header
+			}
+		}
+		b = append(b[:cut], TruncatedMarker...)
+		*buf = append(b, '\n')
+		return nil
+	}
+	body := make([]byte, len(b)-1-header)
+	copy(body, b[header:])
+	b = b[:header]
+	space := max - header - 1
+	var ends []int
+	for len(body) > 0 {
+		if ends != nil {
+			b = append(b, b[:header]...)
+			b = append(b, ContinuationMarker...)
+			space = room
+		}
+		n := len(body)
+		if n > space {
+			n = runeStart(body, space)
+		}
+		b = append(b, body[:n]...)
+		b = append(b, '\n')
+		ends = append(ends, len(b))
+		body = body[n:]
+	}
+	*buf = b
+	return ends
+}
+
+// runeStart returns the greatest index not above i that starts a rune
+// of b, so that cutting b there does not split a UTF-8 sequence.
+func runeStart(b []byte, i int) int {
+	for j := i; j > 0 && j > i-utf8.UTFMax; j-- {
+		if utf8.RuneStart(b[j]) {
+			return j
+		}
+	}
+	return i
+}
+
+// writeLines writes the lines of buf ending at ends, or all of buf in a
+// single call if ends is nil.
+func writeLines(w io.Writer, buf []byte, ends []int) (n int, err error) {
+	if ends == nil {
+		return w.Write(buf)
+	}
+	start := 0
+	for _, end := range ends {
+		m, err := w.Write(buf[start:end])
+		n += m
+		if err != nil {
+			return n, err
+		}
+		start = end
+	}
+	return n, nil
+}
+
+// appendSingleLine appends s to buf with its carriage returns and line
+// feeds escaped as \r and \n.
+func appendSingleLine(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\n':
+			buf = append(buf, `\n`...)
+		case '\r':
+			buf = append(buf, `\r`...)
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// writesWriter records every write.
+type writesWriter struct {
+	writes []string
+}
+
+func (w *writesWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func TestMaxLineLengthTruncate(t *testing.T) {
+	var w writesWriter
+	l := New(&w, 0, LevelAll)
+	l.SetMaxLineLength(10, false) // raised to MinLineLength
+	l.Info(strings.Repeat("a", 100))
+	l.Info("short")
+	want := "INFO " + strings.Repeat("a", MinLineLength-len("INFO ")-len(TruncatedMarker)-1) + TruncatedMarker + "\n"
+	if len(w.writes) != 2 || w.writes[0] != want || w.writes[1] != "INFO short\n" {
+		t.Errorf("writes = %q, want %q", w.writes, want)
+	}
+	if len(w.writes[0]) != MinLineLength {
+		t.Errorf("line of %d bytes, want %d", len(w.writes[0]), MinLineLength)
+	}
+}
+
+func TestMaxLineLengthSplit(t *testing.T) {
+	for _, msg := range []string{
+		strings.Repeat("0123456789", 20),
+		strings.Repeat("é", 100),
+		strings.Repeat("x", 54), // fits exactly
+	} {
+		var w writesWriter
+		l := New(&w, 0, LevelAll)
+		l.SetMaxLineLength(64, true)
+		l.With("k", "v").Warning(msg)
+		var got bytes.Buffer
+		for i, line := range w.writes {
+			if len(line) > 64 || !utf8.ValidString(line) || strings.Count(line, "\n") != 1 {
+				t.Errorf("invalid line %q", line)
+			}
+			prefix := "WARN "
+			if i > 0 {
+				prefix += ContinuationMarker
+			}
+			if !strings.HasPrefix(line, prefix) {
+				t.Errorf("line %q does not start with %q", line, prefix)
+			}
+			got.WriteString(strings.TrimSuffix(strings.TrimPrefix(line, prefix), "\n"))
+		}
+		if want := msg + " k=v"; got.String() != want {
+			t.Errorf("joined lines = %q, want %q", got.String(), want)
+		}
+	}
+}
+
+func TestSingleLine(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b, 0, LevelAll)
+	l.SetFlags(Lsingleline)
+	l.Info("user input\nERRO forged\r\n")
+	if want := `INFO user input\nERRO forged\r` + "\n"; b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+	b.Reset()
+	l.With("k\nERRO 2009/11/10 forged", 1).Info("key")
+	if want := `INFO key k\nERRO 2009/11/10 forged=1` + "\n"; b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
+
+func TestMaxLineLengthLongHeader(t *testing.T) {
+	var w writesWriter
+	l := New(&w, Ldate|Ltime|Llongfile|Lfunc|Lpackage, LevelAll)
+	l.SetMaxLineLength(MinLineLength, false)
+	l.With("user", "gopher").Info("message")
+	if len(w.writes) != 1 {
+		t.Fatalf("writes = %q", w.writes)
+	}
+	line := w.writes[0]
+	if !strings.Contains(line, "limit_test.go:") || !strings.HasSuffix(line, ".TestMaxLineLengthLongHeader: "+TruncatedMarker+"\n") {
+		t.Errorf("header cut: %q", line)
+	}
+	if r, err := Parse(line, Ldate|Ltime|Llongfile|Lfunc|Lpackage); err != nil || r.Message != TruncatedMarker {
+		t.Errorf("Parse(%q) = %+v, %v", line, r, err)
+	}
+}
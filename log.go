@@ -9,16 +9,19 @@
 // Panic[f|ln], which are easier to use than creating a Logger manually.
 // That logger writes to standard error and prints the date and time
 // of each logged message.
-// The Fatal functions call os.Exit(1) after writing the log message.
+// The Fatal functions call os.Exit(1) after writing and flushing the log message.
 // The Panic functions call panic after writing the log message.
+// Recover, deferred, logs a panic with its stack trace.
 package log
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
-	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -33,13 +36,27 @@ const (
 	//	2009/01/23 01:23:23 message
 	// while flags Ldate | Ltime | Lmicroseconds | Llongfile produce,
 	//	2009/01/23 01:23:23.123123 /a/b/c/d.go:23: message
+	// and flags Lshortfile | Lfunc produce,
+	//	d.go:23: (*Server).handle: message
 	Ldate         = 1 << iota     // the date in the local time zone: 2009/01/23
 	Ltime                         // the time in the local time zone: 01:23:23
 	Lmicroseconds                 // microsecond resolution: 01:23:23.123123.  assumes Ltime.
 	Llongfile                     // full file name and line number: /a/b/c/d.go:23
 	Lshortfile                    // final file name element and line number: d.go:23. overrides Llongfile
 	LUTC                          // if Ldate or Ltime is set, use UTC rather than the local time zone
+	Lrelfile                      // file name relative to its module root or GOPATH: github.com/a/b/d.go:23. overrides Llongfile
+	Lsortfields                   // fields sorted by key rather than in the order they were added
+	Lsingleline                   // newlines and carriage returns in messages and keys escaped as \n and \r
+	Lsanitize                     // control characters and invalid UTF-8 in messages and keys escaped. overrides Lsingleline
+	Lfunc                         // function name of the caller within its package: (*Server).handle
+	Lpackage                      // package path of the caller: github.com/a/b. with Lfunc: github.com/a/b.(*Server).handle
 	LstdFlags     = Ldate | Ltime // initial values for the standard logger
+
+	// lfile is the set of flags that write the caller file.
+	lfile = Llongfile | Lshortfile | Lrelfile
+
+	// lcaller is the set of flags that need caller information.
+	lcaller = lfile | Lfunc | Lpackage
 )
 
 // levels.
@@ -65,21 +82,119 @@ const (
 	prefixFatal   = "FATA "
 )
 
+// prefixLevel is the inverse of levelPrefix. It returns 0 for any prefix
+// that does not belong to a predefined level.
+func prefixLevel(prefix string) int {
+	switch prefix {
+	case prefixDebug:
+		return LevelDebug
+	case prefixInfo:
+		return LevelInfo
+	case prefixWarning:
+		return LevelWarning
+	case prefixError:
+		return LevelError
+	case prefixFatal:
+		return LevelFatal
+	}
+	return 0
+}
+
+// levelPrefix returns the prefix of the given level, or prefixEmpty if
+// level is not exactly one of the predefined levels.
+func levelPrefix(level int) string {
+	switch level {
+	case LevelDebug:
+		return prefixDebug
+	case LevelInfo:
+		return prefixInfo
+	case LevelWarning:
+		return prefixWarning
+	case LevelError:
+		return prefixError
+	case LevelFatal:
+		return prefixFatal
+	}
+	return prefixEmpty
+}
+
 // ignore return bool indicate whether the current level's log should be ignored.
+// Ignored records are counted as dropped.
 func (l *Logger) ignore(level int) bool {
-	return (l.level & level) == 0
+	if l.enabled(level) {
+		return false
+	}
+	atomic.AddUint64(&l.counters.dropped[levelIndex(level)], 1)
+	return true
+}
+
+// enabled reports whether any of the given levels is enabled.
+func (l *Logger) enabled(level int) bool {
+	return int(atomic.LoadInt32(&l.level))&level != 0
 }
 
 // A Logger represents an active logging object that generates lines of
 // output to an io.Writer. Each logging operation makes a single call to
-// the Writer's Write method. A Logger can be used simultaneously from
-// multiple goroutines; it guarantees to serialize access to the Writer.
+// the Writer's Write method, unless SetMaxLineLength splits its record
+// into several lines, each written by its own call. A Logger can be used
+// simultaneously from multiple goroutines; it guarantees to serialize
+// access to the Writer.
+//
+// The levels and flags are read atomically, so checking whether a level
+// is enabled never blocks on the mutex.
 type Logger struct {
-	mu    sync.Mutex // ensures atomic writes; protects the following fields
-	level int        // logging level
-	flag  int        // properties
-	out   io.Writer  // destination for output
-	buf   []byte     // for accumulating text to write
+	*core              // shared with the loggers derived by With
+	fields   []Field   // attached to every record
+	counters *counters // statistics of the name of the logger
+}
+
+// core is the state shared by a Logger and the loggers derived from it.
+type core struct {
+	level     int32                // logging level; accessed atomically
+	flag      int32                // properties; accessed atomically
+	mu        sync.Mutex           // ensures atomic writes; protects the following fields
+	out       io.Writer            // destination for output
+	handler   Handler              // if not nil, receives records instead of out
+	clock     func() time.Time     // if not nil, used instead of time.Now
+	extractor TraceExtractor       // if not nil, used instead of TraceparentExtractor
+	redactor  *Redactor            // if not nil, applied to every record
+	hooks     []hook               // copied on write
+	named     map[string]*counters // by name, for Stats
+
+	errorHandler ErrorHandler  // if nil, errors are reported to standard error
+	fallback     io.Writer     // if not nil, written when out or handler fail
+	maxFailures  int           // consecutive failures opening the circuit breaker
+	retry        time.Duration // interval between retries of an open breaker
+	failures     int           // consecutive failures of out or handler
+	retryAt      time.Time     // time of the next retry of an open breaker
+	maxLine      int           // maximum length of the lines, if positive
+	splitLine    bool          // whether longer lines are split rather than truncated
+}
+
+// bufPool holds buffers for accumulating text to write, so that
+// formatting can happen outside of the Logger's lock.
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// maxPooledBuffer is the capacity above which buffers are not returned
+// to bufPool, so that a single huge message does not pin its memory.
+const maxPooledBuffer = 64 << 10
+
+func getBuffer() *[]byte {
+	buf := bufPool.Get().(*[]byte)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+func putBuffer(buf *[]byte) {
+	if cap(*buf) > maxPooledBuffer {
+		return
+	}
+	bufPool.Put(buf)
 }
 
 // New creates a new Logger. The out variable sets the
@@ -87,7 +202,16 @@ type Logger struct {
 // The prefix appears at the beginning of each generated log line.
 // The flag argument defines the logging properties.
 func New(out io.Writer, flag, level int) *Logger {
-	return &Logger{out: out, flag: flag, level: level}
+	c := &counters{}
+	return &Logger{
+		core: &core{
+			out:   out,
+			flag:  int32(flag),
+			level: int32(level),
+			named: map[string]*counters{"": c},
+		},
+		counters: c,
+	}
 }
 
 // SetOutput sets the output destination for the logger.
@@ -97,6 +221,78 @@ func (l *Logger) SetOutput(w io.Writer) {
 	l.out = w
 }
 
+// Flush flushes the output and the handler of the logger that have a
+// Flush() error method, such as a bufio.Writer or a handler that batches
+// records. A FlightRecorder handler keeps the records it holds.
+func (l *Logger) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.flush()
+}
+
+// flush flushes the output and the handler. l.mu must be held.
+func (l *Logger) flush() error {
+	var err error
+	for _, v := range []interface{}{l.out, l.handler} {
+		if f, ok := v.(interface {
+			Flush() error
+		}); ok {
+			if ferr := f.Flush(); err == nil {
+				err = ferr
+			}
+		}
+	}
+	return err
+}
+
+// Close flushes the logger, then closes its output and its handler if they
+// are io.Closers, other than os.Stdout and os.Stderr. The records logged
+// after Close fail as the closed output or handler do.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.flush()
+	for _, v := range []interface{}{l.out, l.handler} {
+		if v == os.Stdout || v == os.Stderr {
+			continue
+		}
+		if c, ok := v.(io.Closer); ok {
+			if cerr := c.Close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	return err
+}
+
+// SetHandler sets the handler for the logger. If h is not nil, every
+// logging event is passed to it as a Record instead of being formatted
+// and written to the output destination. Calls to h are serialized.
+func (l *Logger) SetHandler(h Handler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handler = h
+}
+
+// SetClock sets the function used to get the time of each logging event.
+// A nil clock means time.Now. A fixed clock makes the output deterministic,
+// which is useful in tests.
+func (l *Logger) SetClock(clock func() time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clock = clock
+}
+
+// With returns a Logger that attaches the given key/value pairs to every
+// record. The returned Logger shares its output, handler, flags and levels
+// with l.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	fields := make([]Field, 0, len(l.fields)+(len(keyvals)+1)/2)
+	fields = append(fields, l.fields...)
+	fields = appendKeyvals(fields, keyvals)
+	return &Logger{core: l.core, fields: fields, counters: l.counters}
+}
+
 var std = New(os.Stderr, LstdFlags, LevelAll)
 
 // Cheap integer to fixed-width decimal ASCII.  Give a negative width to avoid zero-padding.
@@ -116,13 +312,13 @@ func itoa(buf *[]byte, i int, wid int) {
 	*buf = append(*buf, b[bp:]...)
 }
 
-func (l *Logger) formatHeader(buf *[]byte, prefix string, t time.Time, file string, line int) {
+func formatHeader(buf *[]byte, flag int, prefix string, t time.Time, file string, line int, function string) {
 	*buf = append(*buf, prefix...)
-	if l.flag&LUTC != 0 {
+	if flag&LUTC != 0 {
 		t = t.UTC()
 	}
-	if l.flag&(Ldate|Ltime|Lmicroseconds) != 0 {
-		if l.flag&Ldate != 0 {
+	if flag&(Ldate|Ltime|Lmicroseconds) != 0 {
+		if flag&Ldate != 0 {
 			year, month, day := t.Date()
 			itoa(buf, year, 4)
 			*buf = append(*buf, '/')
@@ -131,22 +327,22 @@ func (l *Logger) formatHeader(buf *[]byte, prefix string, t time.Time, file stri
 			itoa(buf, day, 2)
 			*buf = append(*buf, ' ')
 		}
-		if l.flag&(Ltime|Lmicroseconds) != 0 {
+		if flag&(Ltime|Lmicroseconds) != 0 {
 			hour, min, sec := t.Clock()
 			itoa(buf, hour, 2)
 			*buf = append(*buf, ':')
 			itoa(buf, min, 2)
 			*buf = append(*buf, ':')
 			itoa(buf, sec, 2)
-			if l.flag&Lmicroseconds != 0 {
+			if flag&Lmicroseconds != 0 {
 				*buf = append(*buf, '.')
 				itoa(buf, t.Nanosecond()/1e3, 6)
 			}
 			*buf = append(*buf, ' ')
 		}
 	}
-	if l.flag&(Lshortfile|Llongfile) != 0 {
-		if l.flag&Lshortfile != 0 {
+	if flag&lfile != 0 {
+		if flag&Lshortfile != 0 {
 			short := file
 			for i := len(file) - 1; i > 0; i-- {
 				if file[i] == '/' {
@@ -161,6 +357,29 @@ func (l *Logger) formatHeader(buf *[]byte, prefix string, t time.Time, file stri
 		itoa(buf, line, -1)
 		*buf = append(*buf, ": "...)
 	}
+	if flag&(Lfunc|Lpackage) != 0 {
+		*buf = append(*buf, funcHeader(flag, function)...)
+		*buf = append(*buf, ": "...)
+	}
+}
+
+// formatRecord appends the text representation of r to buf: the header,
+// the message, the fields and a newline. It returns the length of buf
+// after the header.
+func formatRecord(buf *[]byte, flag int, prefix string, r *Record) int {
+	formatHeader(buf, flag, prefix, r.Time, r.File, r.Line, r.Function)
+	header := len(*buf)
+	switch {
+	case flag&Lsanitize != 0:
+		*buf = appendSanitized(*buf, r.Message)
+	case flag&Lsingleline != 0:
+		*buf = appendSingleLine(*buf, r.Message)
+	default:
+		*buf = append(*buf, r.Message...)
+	}
+	*buf = appendFields(*buf, r.Fields, flag)
+	*buf = append(*buf, '\n')
+	return header
 }
 
 // Output writes the output for a logging event. The string s contains
@@ -170,48 +389,95 @@ func (l *Logger) formatHeader(buf *[]byte, prefix string, t time.Time, file stri
 // provided for generality, although at the moment on all pre-defined
 // paths it will be 2.
 func (l *Logger) Output(calldepth int, s string, prefix string) error {
-	now := time.Now() // get this early.
-	var file string
-	var line int
 	l.mu.Lock()
-	defer l.mu.Unlock()
-	if l.flag&(Lshortfile|Llongfile) != 0 {
-		// release lock while getting caller info - it's expensive.
-		l.mu.Unlock()
-		var ok bool
-		_, file, line, ok = runtime.Caller(calldepth)
-		if !ok {
+	h, clock, redactor, hooks, eh := l.handler, l.clock, l.redactor, l.hooks, l.errorHandler
+	maxLine, splitLine := l.maxLine, l.splitLine
+	l.mu.Unlock()
+	var now time.Time // get this early.
+	if clock != nil {
+		now = clock()
+	} else {
+		now = time.Now()
+	}
+	var pc uintptr
+	var file, function string
+	var line int
+	flag := l.Flags()
+	if flag&lcaller != 0 {
+		// get caller info before taking the lock - it's expensive the
+		// first time for each call site.
+		if f := callerFrame(calldepth, flag&Lrelfile != 0); f != nil {
+			pc, function = f.pc, f.function
+			if flag&lfile != 0 {
+				file, line = f.file, f.line
+				if flag&Lrelfile != 0 {
+					file = f.relFile
+				}
+			}
+		} else if flag&lfile != 0 {
 			file = "???"
-			line = 0
 		}
-		l.mu.Lock()
 	}
-	l.buf = l.buf[:0]
-	l.formatHeader(&l.buf, prefix, now, file, line)
-	l.buf = append(l.buf, s...)
-	if len(s) == 0 || s[len(s)-1] != '\n' {
-		l.buf = append(l.buf, '\n')
+	fields := resolveFields(l.fields)
+	if flag&Lsortfields != 0 {
+		fields = sortFields(fields)
+	}
+	r := Record{
+		Time:     now,
+		Level:    prefixLevel(prefix),
+		Message:  strings.TrimSuffix(s, "\n"),
+		File:     file,
+		Line:     line,
+		Function: function,
+		PC:       pc,
+		Fields:   fields,
+	}
+	if redactor != nil {
+		redactor.Redact(&r)
+	}
+	runHooks(hooks, r, eh)
+	var buf *[]byte
+	var ends []int
+	if h == nil {
+		buf = getBuffer()
+		defer putBuffer(buf)
+		ends = formatLimited(buf, flag, prefix, &r, maxLine, splitLine)
+	}
+	// only the write itself is serialized.
+	l.mu.Lock()
+	lost, perr, ferr := l.emit(h, buf, ends, flag, prefix, &r)
+	l.mu.Unlock()
+	if perr != nil {
+		handleError(eh, perr, r)
 	}
-	_, err := l.out.Write(l.buf)
-	return err
+	if ferr != nil {
+		handleError(eh, ferr, r)
+	}
+	switch {
+	case !lost:
+		return nil
+	case ferr != nil:
+		return ferr
+	}
+	return perr
 }
 
 // Printf calls l.Output to print to the logger.
 // Arguments are handled in the manner of fmt.Printf.
 func (l *Logger) Printf(format string, v ...interface{}) {
-	l.Output(2, fmt.Sprintf(format, v...), prefixEmpty)
+	l.Output(2, fmt.Sprintf(format, resolveArgs(v)...), prefixEmpty)
 }
 
 // Print calls l.Output to print to the logger.
 // Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Print(v ...interface{}) {
-	l.Output(2, fmt.Sprint(v...), prefixEmpty)
+	l.Output(2, fmt.Sprint(resolveArgs(v)...), prefixEmpty)
 }
 
 // Println calls l.Output to print to the logger.
 // Arguments are handled in the manner of fmt.Println.
 func (l *Logger) Println(v ...interface{}) {
-	l.Output(2, fmt.Sprintln(v...), prefixEmpty)
+	l.Output(2, fmt.Sprintln(resolveArgs(v)...), prefixEmpty)
 }
 
 // Debug calls l.Output to print to the logger.
@@ -220,7 +486,7 @@ func (l *Logger) Debug(v ...interface{}) {
 	if l.ignore(LevelDebug) {
 		return
 	}
-	l.Output(2, fmt.Sprint(v...), prefixDebug)
+	l.Output(2, fmt.Sprint(resolveArgs(v)...), prefixDebug)
 }
 
 // Debugf calls Output to print to the standard logger.
@@ -229,7 +495,7 @@ func (l *Logger) Debugf(format string, v ...interface{}) {
 	if l.ignore(LevelDebug) {
 		return
 	}
-	l.Output(2, fmt.Sprintf(format, v...), prefixDebug)
+	l.Output(2, fmt.Sprintf(format, resolveArgs(v)...), prefixDebug)
 }
 
 // Debugln calls Output to print to the standard logger.
@@ -238,7 +504,7 @@ func (l *Logger) Debugln(v ...interface{}) {
 	if l.ignore(LevelDebug) {
 		return
 	}
-	l.Output(2, fmt.Sprintln(v...), prefixDebug)
+	l.Output(2, fmt.Sprintln(resolveArgs(v)...), prefixDebug)
 }
 
 // Info calls l.Output to print to the logger.
@@ -247,7 +513,7 @@ func (l *Logger) Info(v ...interface{}) {
 	if l.ignore(LevelInfo) {
 		return
 	}
-	l.Output(2, fmt.Sprint(v...), prefixInfo)
+	l.Output(2, fmt.Sprint(resolveArgs(v)...), prefixInfo)
 }
 
 // Infof calls Output to print to the standard logger.
@@ -256,7 +522,7 @@ func (l *Logger) Infof(format string, v ...interface{}) {
 	if l.ignore(LevelInfo) {
 		return
 	}
-	l.Output(2, fmt.Sprintf(format, v...), prefixInfo)
+	l.Output(2, fmt.Sprintf(format, resolveArgs(v)...), prefixInfo)
 }
 
 // Infoln calls Output to print to the standard logger.
@@ -265,7 +531,7 @@ func (l *Logger) Infoln(v ...interface{}) {
 	if l.ignore(LevelInfo) {
 		return
 	}
-	l.Output(2, fmt.Sprintln(v...), prefixInfo)
+	l.Output(2, fmt.Sprintln(resolveArgs(v)...), prefixInfo)
 }
 
 // Warning calls l.Output to print to the logger.
@@ -274,7 +540,7 @@ func (l *Logger) Warning(v ...interface{}) {
 	if l.ignore(LevelWarning) {
 		return
 	}
-	l.Output(2, fmt.Sprint(v...), prefixWarning)
+	l.Output(2, fmt.Sprint(resolveArgs(v)...), prefixWarning)
 }
 
 // Warningf calls Output to print to the standard logger.
@@ -283,7 +549,7 @@ func (l *Logger) Warningf(format string, v ...interface{}) {
 	if l.ignore(LevelWarning) {
 		return
 	}
-	l.Output(2, fmt.Sprintf(format, v...), prefixWarning)
+	l.Output(2, fmt.Sprintf(format, resolveArgs(v)...), prefixWarning)
 }
 
 // Warningln calls Output to print to the standard logger.
@@ -292,7 +558,7 @@ func (l *Logger) Warningln(v ...interface{}) {
 	if l.ignore(LevelWarning) {
 		return
 	}
-	l.Output(2, fmt.Sprintln(v...), prefixWarning)
+	l.Output(2, fmt.Sprintln(resolveArgs(v)...), prefixWarning)
 }
 
 // Error calls l.Output to print to the logger.
@@ -301,7 +567,7 @@ func (l *Logger) Error(v ...interface{}) {
 	if l.ignore(LevelError) {
 		return
 	}
-	l.Output(2, fmt.Sprint(v...), prefixError)
+	l.Output(2, fmt.Sprint(resolveArgs(v)...), prefixError)
 }
 
 // Errorf calls Output to print to the standard logger.
@@ -310,7 +576,7 @@ func (l *Logger) Errorf(format string, v ...interface{}) {
 	if l.ignore(LevelError) {
 		return
 	}
-	l.Output(2, fmt.Sprintf(format, v...), prefixError)
+	l.Output(2, fmt.Sprintf(format, resolveArgs(v)...), prefixError)
 }
 
 // Errorln calls Output to print to the standard logger.
@@ -319,7 +585,7 @@ func (l *Logger) Errorln(v ...interface{}) {
 	if l.ignore(LevelError) {
 		return
 	}
-	l.Output(2, fmt.Sprintln(v...), prefixError)
+	l.Output(2, fmt.Sprintln(resolveArgs(v)...), prefixError)
 }
 
 // Fatal calls Output to print to the standard logger.
@@ -328,7 +594,7 @@ func (l *Logger) Fatal(v ...interface{}) {
 	if l.ignore(LevelFatal) {
 		return
 	}
-	l.Output(2, fmt.Sprint(v...), prefixFatal)
+	l.Output(2, fmt.Sprint(resolveArgs(v)...), prefixFatal)
 }
 
 // Fatalf calls Output to print to the standard logger.
@@ -337,7 +603,7 @@ func (l *Logger) Fatalf(format string, v ...interface{}) {
 	if l.ignore(LevelFatal) {
 		return
 	}
-	l.Output(2, fmt.Sprintf(format, v...), prefixFatal)
+	l.Output(2, fmt.Sprintf(format, resolveArgs(v)...), prefixFatal)
 }
 
 // Fatalln calls Output to print to the standard logger.
@@ -346,56 +612,116 @@ func (l *Logger) Fatalln(v ...interface{}) {
 	if l.ignore(LevelFatal) {
 		return
 	}
-	l.Output(2, fmt.Sprintln(v...), prefixFatal)
+	l.Output(2, fmt.Sprintln(resolveArgs(v)...), prefixFatal)
 }
 
 // Panic is equivalent to l.Print() followed by a call to panic().
 func (l *Logger) Panic(v ...interface{}) {
-	s := fmt.Sprint(v...)
+	s := fmt.Sprint(resolveArgs(v)...)
 	l.Output(2, s, prefixEmpty)
 	panic(s)
 }
 
 // Panicf is equivalent to l.Printf() followed by a call to panic().
 func (l *Logger) Panicf(format string, v ...interface{}) {
-	s := fmt.Sprintf(format, v...)
+	s := fmt.Sprintf(format, resolveArgs(v)...)
 	l.Output(2, s, prefixEmpty)
 	panic(s)
 }
 
 // Panicln is equivalent to l.Println() followed by a call to panic().
 func (l *Logger) Panicln(v ...interface{}) {
-	s := fmt.Sprintln(v...)
+	s := fmt.Sprintln(resolveArgs(v)...)
 	l.Output(2, s, prefixEmpty)
 	panic(s)
 }
 
 // Flags returns the output flags for the logger.
 func (l *Logger) Flags() int {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	return l.flag
+	return int(atomic.LoadInt32(&l.flag))
 }
 
 // SetFlags sets the output flags for the logger.
 func (l *Logger) SetFlags(flag int) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.flag = flag
+	atomic.StoreInt32(&l.flag, int32(flag))
 }
 
 // Levels returns the levels for the logger.
 func (l *Logger) Levels() int {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	return l.level
+	return int(atomic.LoadInt32(&l.level))
 }
 
 // SetLevels sets the levels for the logger.
 func (l *Logger) SetLevels(level int) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.level = level
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+// Enabled reports whether any of the given levels is enabled for the logger.
+// It is cheap enough to guard the construction of expensive arguments:
+//
+//	if logger.Enabled(LevelDebug) {
+//		logger.Debug(dump(state))
+//	}
+func (l *Logger) Enabled(level int) bool {
+	return l.enabled(level)
+}
+
+// V returns a Verbose that logs at the given level if it is enabled,
+// and discards everything otherwise. The records it discards count as
+// dropped in Stats, as those of the other methods do; calling Enabled
+// does not.
+func (l *Logger) V(level int) Verbose {
+	return Verbose{l: l, prefix: levelPrefix(level), level: level, on: l.enabled(level)}
+}
+
+// Verbose is returned by V. Its zero value is disabled.
+type Verbose struct {
+	l      *Logger
+	prefix string
+	level  int
+	on     bool
+}
+
+// Enabled reports whether the level passed to V is enabled.
+func (v Verbose) Enabled() bool {
+	return v.on
+}
+
+// drop counts a discarded record.
+func (v Verbose) drop() {
+	if v.l != nil {
+		v.l.ignore(v.level)
+	}
+}
+
+// Print calls Output to print to the logger if v is enabled.
+// Arguments are handled in the manner of fmt.Print.
+func (v Verbose) Print(args ...interface{}) {
+	if !v.on {
+		v.drop()
+		return
+	}
+	v.l.Output(2, fmt.Sprint(resolveArgs(args)...), v.prefix)
+}
+
+// Printf calls Output to print to the logger if v is enabled.
+// Arguments are handled in the manner of fmt.Printf.
+func (v Verbose) Printf(format string, args ...interface{}) {
+	if !v.on {
+		v.drop()
+		return
+	}
+	v.l.Output(2, fmt.Sprintf(format, resolveArgs(args)...), v.prefix)
+}
+
+// Println calls Output to print to the logger if v is enabled.
+// Arguments are handled in the manner of fmt.Println.
+func (v Verbose) Println(args ...interface{}) {
+	if !v.on {
+		v.drop()
+		return
+	}
+	v.l.Output(2, fmt.Sprintln(resolveArgs(args)...), v.prefix)
 }
 
 // SetOutput sets the output destination for the standard logger.
@@ -405,6 +731,69 @@ func SetOutput(w io.Writer) {
 	std.out = w
 }
 
+// SetHandler sets the handler for the standard logger.
+func SetHandler(h Handler) {
+	std.SetHandler(h)
+}
+
+// SetRedactor sets the Redactor for the standard logger.
+func SetRedactor(r *Redactor) {
+	std.SetRedactor(r)
+}
+
+// AddHook adds a hook called with the records of the given levels
+// logged by the standard logger.
+func AddHook(levels int, fn func(Record) error) {
+	std.AddHook(levels, fn)
+}
+
+// SetErrorHandler sets the ErrorHandler for the standard logger.
+func SetErrorHandler(h ErrorHandler) {
+	std.SetErrorHandler(h)
+}
+
+// SetFallback sets the fallback output for the standard logger.
+func SetFallback(w io.Writer, failures int, retry time.Duration) {
+	std.SetFallback(w, failures, retry)
+}
+
+// SetMaxLineLength limits the length of the lines of the standard logger.
+func SetMaxLineLength(n int, split bool) {
+	std.SetMaxLineLength(n, split)
+}
+
+// Flush flushes the standard logger.
+func Flush() error {
+	return std.Flush()
+}
+
+// SetClock sets the clock for the standard logger.
+func SetClock(clock func() time.Time) {
+	std.SetClock(clock)
+}
+
+// SetTraceExtractor sets the TraceExtractor for the standard logger.
+func SetTraceExtractor(e TraceExtractor) {
+	std.SetTraceExtractor(e)
+}
+
+// WithContext returns a Logger sharing the standard logger that attaches
+// the trace and span IDs carried by ctx to every record.
+func WithContext(ctx context.Context) *Logger {
+	return std.WithContext(ctx)
+}
+
+// Named returns a Logger sharing the standard logger whose records are
+// counted under the given name.
+func Named(name string) *Logger {
+	return std.Named(name)
+}
+
+// Stats returns the statistics of the standard logger.
+func Stats() []LoggerStats {
+	return std.Stats()
+}
+
 // Flags returns the output flags for the standard logger.
 func Flags() int {
 	return std.Flags()
@@ -425,24 +814,35 @@ func SetLevels(level int) {
 	std.SetLevels(level)
 }
 
+// Enabled reports whether any of the given levels is enabled for the standard logger.
+func Enabled(level int) bool {
+	return std.Enabled(level)
+}
+
+// V returns a Verbose that logs to the standard logger at the given level
+// if it is enabled.
+func V(level int) Verbose {
+	return std.V(level)
+}
+
 // These functions write to the standard logger.
 
 // Print calls Output to print to the standard logger.
 // Arguments are handled in the manner of fmt.Print.
 func Print(v ...interface{}) {
-	std.Output(2, fmt.Sprint(v...), prefixEmpty)
+	std.Output(2, fmt.Sprint(resolveArgs(v)...), prefixEmpty)
 }
 
 // Printf calls Output to print to the standard logger.
 // Arguments are handled in the manner of fmt.Printf.
 func Printf(format string, v ...interface{}) {
-	std.Output(2, fmt.Sprintf(format, v...), prefixEmpty)
+	std.Output(2, fmt.Sprintf(format, resolveArgs(v)...), prefixEmpty)
 }
 
 // Println calls Output to print to the standard logger.
 // Arguments are handled in the manner of fmt.Println.
 func Println(v ...interface{}) {
-	std.Output(2, fmt.Sprintln(v...), prefixEmpty)
+	std.Output(2, fmt.Sprintln(resolveArgs(v)...), prefixEmpty)
 }
 
 // Debug calls l.Output to print to the logger.
@@ -451,7 +851,7 @@ func Debug(v ...interface{}) {
 	if std.ignore(LevelDebug) {
 		return
 	}
-	std.Output(2, fmt.Sprint(v...), prefixDebug)
+	std.Output(2, fmt.Sprint(resolveArgs(v)...), prefixDebug)
 }
 
 // Debugf calls Output to print to the standard logger.
@@ -460,7 +860,7 @@ func Debugf(format string, v ...interface{}) {
 	if std.ignore(LevelDebug) {
 		return
 	}
-	std.Output(2, fmt.Sprintf(format, v...), prefixDebug)
+	std.Output(2, fmt.Sprintf(format, resolveArgs(v)...), prefixDebug)
 }
 
 // Debugln calls Output to print to the standard logger.
@@ -469,7 +869,7 @@ func Debugln(v ...interface{}) {
 	if std.ignore(LevelDebug) {
 		return
 	}
-	std.Output(2, fmt.Sprintln(v...), prefixDebug)
+	std.Output(2, fmt.Sprintln(resolveArgs(v)...), prefixDebug)
 }
 
 // Info calls l.Output to print to the logger.
@@ -478,7 +878,7 @@ func Info(v ...interface{}) {
 	if std.ignore(LevelInfo) {
 		return
 	}
-	std.Output(2, fmt.Sprint(v...), prefixInfo)
+	std.Output(2, fmt.Sprint(resolveArgs(v)...), prefixInfo)
 }
 
 // Infof calls Output to print to the standard logger.
@@ -487,7 +887,7 @@ func Infof(format string, v ...interface{}) {
 	if std.ignore(LevelInfo) {
 		return
 	}
-	std.Output(2, fmt.Sprintf(format, v...), prefixInfo)
+	std.Output(2, fmt.Sprintf(format, resolveArgs(v)...), prefixInfo)
 }
 
 // Infoln calls Output to print to the standard logger.
@@ -496,7 +896,7 @@ func Infoln(v ...interface{}) {
 	if std.ignore(LevelInfo) {
 		return
 	}
-	std.Output(2, fmt.Sprintln(v...), prefixInfo)
+	std.Output(2, fmt.Sprintln(resolveArgs(v)...), prefixInfo)
 }
 
 // Warning calls l.Output to print to the logger.
@@ -505,7 +905,7 @@ func Warning(v ...interface{}) {
 	if std.ignore(LevelWarning) {
 		return
 	}
-	std.Output(2, fmt.Sprint(v...), prefixWarning)
+	std.Output(2, fmt.Sprint(resolveArgs(v)...), prefixWarning)
 }
 
 // Warningf calls Output to print to the standard logger.
@@ -514,7 +914,7 @@ func Warningf(format string, v ...interface{}) {
 	if std.ignore(LevelWarning) {
 		return
 	}
-	std.Output(2, fmt.Sprintf(format, v...), prefixWarning)
+	std.Output(2, fmt.Sprintf(format, resolveArgs(v)...), prefixWarning)
 }
 
 // Warningln calls Output to print to the standard logger.
@@ -523,7 +923,7 @@ func Warningln(v ...interface{}) {
 	if std.ignore(LevelWarning) {
 		return
 	}
-	std.Output(2, fmt.Sprintln(v...), prefixWarning)
+	std.Output(2, fmt.Sprintln(resolveArgs(v)...), prefixWarning)
 }
 
 // Error calls l.Output to print to the logger.
@@ -532,7 +932,7 @@ func Error(v ...interface{}) {
 	if std.ignore(LevelError) {
 		return
 	}
-	std.Output(2, fmt.Sprint(v...), prefixError)
+	std.Output(2, fmt.Sprint(resolveArgs(v)...), prefixError)
 }
 
 // Errorf calls Output to print to the standard logger.
@@ -541,7 +941,7 @@ func Errorf(format string, v ...interface{}) {
 	if std.ignore(LevelError) {
 		return
 	}
-	std.Output(2, fmt.Sprintf(format, v...), prefixError)
+	std.Output(2, fmt.Sprintf(format, resolveArgs(v)...), prefixError)
 }
 
 // Errorln calls Output to print to the standard logger.
@@ -550,53 +950,56 @@ func Errorln(v ...interface{}) {
 	if std.ignore(LevelError) {
 		return
 	}
-	std.Output(2, fmt.Sprintln(v...), prefixError)
+	std.Output(2, fmt.Sprintln(resolveArgs(v)...), prefixError)
 }
 
-// Fatal is equivalent to Print() followed by a call to os.Exit(1).
+// Fatal is equivalent to Print() followed by a call to Flush() and os.Exit(1).
 func Fatal(v ...interface{}) {
 	if std.ignore(LevelFatal) {
 		return
 	}
-	std.Output(2, fmt.Sprint(v...), prefixFatal)
+	std.Output(2, fmt.Sprint(resolveArgs(v)...), prefixFatal)
+	std.Flush()
 	os.Exit(1)
 }
 
-// Fatalf is equivalent to Printf() followed by a call to os.Exit(1).
+// Fatalf is equivalent to Printf() followed by a call to Flush() and os.Exit(1).
 func Fatalf(format string, v ...interface{}) {
 	if std.ignore(LevelFatal) {
 		return
 	}
-	std.Output(2, fmt.Sprintf(format, v...), prefixFatal)
+	std.Output(2, fmt.Sprintf(format, resolveArgs(v)...), prefixFatal)
+	std.Flush()
 	os.Exit(1)
 }
 
-// Fatalln is equivalent to Println() followed by a call to os.Exit(1).
+// Fatalln is equivalent to Println() followed by a call to Flush() and os.Exit(1).
 func Fatalln(v ...interface{}) {
 	if std.ignore(LevelFatal) {
 		return
 	}
-	std.Output(2, fmt.Sprintln(v...), prefixFatal)
+	std.Output(2, fmt.Sprintln(resolveArgs(v)...), prefixFatal)
+	std.Flush()
 	os.Exit(1)
 }
 
 // Panic is equivalent to Print() followed by a call to panic().
 func Panic(v ...interface{}) {
-	s := fmt.Sprint(v...)
+	s := fmt.Sprint(resolveArgs(v)...)
 	std.Output(2, s, prefixEmpty)
 	panic(s)
 }
 
 // Panicf is equivalent to Printf() followed by a call to panic().
 func Panicf(format string, v ...interface{}) {
-	s := fmt.Sprintf(format, v...)
+	s := fmt.Sprintf(format, resolveArgs(v)...)
 	std.Output(2, s, prefixEmpty)
 	panic(s)
 }
 
 // Panicln is equivalent to Println() followed by a call to panic().
 func Panicln(v ...interface{}) {
-	s := fmt.Sprintln(v...)
+	s := fmt.Sprintln(resolveArgs(v)...)
 	std.Output(2, s, prefixEmpty)
 	panic(s)
 }
@@ -605,9 +1008,9 @@ func Panicln(v ...interface{}) {
 // the text to print after the prefix specified by the flags of the
 // Logger. A newline is appended if the last character of s is not
 // already a newline. Calldepth is the count of the number of
-// frames to skip when computing the file name and line number
-// if Llongfile or Lshortfile is set; a value of 1 will print the details
-// for the caller of Output.
+// frames to skip when computing the caller information if
+// Llongfile, Lshortfile, Lrelfile, Lfunc or Lpackage is set; a value
+// of 1 will print the details for the caller of Output.
 func Output(calldepth int, s string) error {
 	return std.Output(calldepth+1, s, prefixEmpty) // +1 for this frame.
 }
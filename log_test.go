@@ -10,9 +10,11 @@ package log
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -21,7 +23,7 @@ const (
 	Rdate         = `[0-9][0-9][0-9][0-9]/[0-9][0-9]/[0-9][0-9]`
 	Rtime         = `[0-9][0-9]:[0-9][0-9]:[0-9][0-9]`
 	Rmicroseconds = `\.[0-9][0-9][0-9][0-9][0-9][0-9]`
-	Rline         = `(56|58):` // must update if the calls to l.Printf / l.Print below move
+	Rline         = `(58|60):` // must update if the calls to l.Printf / l.Print below move
 	Rlongfile     = `.*/[A-Za-z0-9_\-]+\.go:` + Rline
 	Rshortfile    = `[A-Za-z0-9_\-]+\.go:` + Rline
 )
@@ -162,6 +164,56 @@ func TestSetLevels(t *testing.T) {
 	}
 }
 
+func TestEnabled(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b, 0, LevelWarning|LevelError)
+	if l.Enabled(LevelDebug) {
+		t.Error("expected LevelDebug to be disabled")
+	}
+	if !l.Enabled(LevelError) {
+		t.Error("expected LevelError to be enabled")
+	}
+	if !l.Enabled(LevelDebug | LevelWarning) {
+		t.Error("expected LevelDebug|LevelWarning to be enabled")
+	}
+	l.V(LevelDebug).Print("ignored")
+	l.V(LevelWarning).Printf("%d", 1)
+	l.V(LevelError).Println("two")
+	if expect := "WARN 1\nERRO two\n"; b.String() != expect {
+		t.Errorf("log output should match %q is %q", expect, b.String())
+	}
+}
+
+// TestConcurrentLevels is meant to be run with -race.
+func TestConcurrentLevels(t *testing.T) {
+	l := New(ioutil.Discard, LstdFlags, LevelAll)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				l.Debug("debug")
+				l.Error("error")
+				l.V(LevelInfo).Print("info")
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if j%2 == 0 {
+					l.SetLevels(LevelError)
+					l.SetFlags(Lshortfile)
+				} else {
+					l.SetLevels(LevelAll)
+					l.SetFlags(LstdFlags)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
 func BenchmarkItoa(b *testing.B) {
 	dst := make([]byte, 0, 64)
 	for i := 0; i < b.N; i++ {
@@ -185,3 +237,113 @@ func BenchmarkPrintln(b *testing.B) {
 		l.Println(testString)
 	}
 }
+
+func BenchmarkIgnored(b *testing.B) {
+	l := New(ioutil.Discard, LstdFlags, LevelError)
+	for i := 0; i < b.N; i++ {
+		l.Debug("test")
+	}
+}
+
+func BenchmarkEnabledParallel(b *testing.B) {
+	l := New(ioutil.Discard, LstdFlags, LevelError)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Enabled(LevelDebug)
+		}
+	})
+}
+
+// Run with -cpu 1,2,4,8 to compare throughput across GOMAXPROCS values.
+func BenchmarkPrintlnParallel(b *testing.B) {
+	const testString = "test"
+	l := New(ioutil.Discard, LstdFlags|Lmicroseconds, LevelAll)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Println(testString)
+		}
+	})
+}
+
+func BenchmarkPrintfParallel(b *testing.B) {
+	l := New(ioutil.Discard, LstdFlags|Lmicroseconds, LevelAll)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Printf("hello %d %s %v", 23, "world", 1.5)
+		}
+	})
+}
+
+func TestWith(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b, 0, LevelAll)
+	l.With("user", "gopher", "msg", "hello world", "odd").Infoln("login")
+	l.Info("plain")
+	expect := "INFO login user=gopher msg=\"hello world\" odd=(MISSING)\nINFO plain\n"
+	if b.String() != expect {
+		t.Errorf("log output should match %q is %q", expect, b.String())
+	}
+}
+
+func TestSetHandler(t *testing.T) {
+	var records []Record
+	l := New(nil, Lshortfile, LevelAll)
+	l.SetHandler(HandlerFunc(func(r Record) error {
+		records = append(records, r)
+		return nil
+	}))
+	l.With("k", 1).Warningln("careful")
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	r := records[0]
+	if r.Level != LevelWarning || r.Message != "careful" || r.Line == 0 || len(r.Fields) != 1 {
+		t.Errorf("unexpected record %+v", r)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b, LstdFlags|Lmicroseconds|LUTC|Lsortfields, LevelAll)
+	l.SetClock(func() time.Time {
+		return time.Date(2009, 11, 10, 23, 0, 0, 123456000, time.UTC)
+	})
+	l.With("b", 2, "a", 1, "b", 0).Info("hello")
+	expect := "INFO 2009/11/10 23:00:00.123456 hello a=1 b=2 b=0\n"
+	if b.String() != expect {
+		t.Errorf("log output should match %q is %q", expect, b.String())
+	}
+}
+
+// flushCloser records the calls to Flush and Close.
+type flushCloser struct {
+	bytes.Buffer
+	calls []string
+}
+
+func (f *flushCloser) Flush() error {
+	f.calls = append(f.calls, "flush")
+	return nil
+}
+
+func (f *flushCloser) Close() error {
+	f.calls = append(f.calls, "close")
+	return nil
+}
+
+func TestFlushClose(t *testing.T) {
+	var out flushCloser
+	l := New(&out, 0, LevelAll)
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(out.calls, " "), "flush flush close"; got != want {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+	if err := New(os.Stderr, 0, LevelAll).Close(); err != nil {
+		t.Errorf("Close of a logger to os.Stderr = %v", err)
+	}
+}
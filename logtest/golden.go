@@ -0,0 +1,66 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logtest
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-gem/log"
+)
+
+// UpdateGolden makes AssertGolden write the golden files rather than
+// compare them. The package defines no flag for it, so that it does not
+// clash with the flags of the tests; set it from one of them:
+//
+//	func init() {
+//		flag.BoolVar(&logtest.UpdateGolden, "update", false, "update golden files")
+//	}
+var UpdateGolden bool
+
+// Epoch is the time of every record logged by a Logger returned by
+// NewDeterministic.
+var Epoch = time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+
+// NewDeterministic returns a Logger writing to w whose output does not
+// depend on when or on which machine it runs: the clock is fixed to Epoch,
+// times are printed in UTC, fields are sorted and Llongfile is replaced
+// with Lrelfile.
+func NewDeterministic(w io.Writer, flag, level int) *log.Logger {
+	if flag&log.Llongfile != 0 {
+		flag = flag&^log.Llongfile | log.Lrelfile
+	}
+	l := log.New(w, flag|log.LUTC|log.Lsortfields, level)
+	l.SetClock(func() time.Time { return Epoch })
+	return l
+}
+
+// AssertGolden fails the test if got differs from the content of
+// testdata/name.golden. If UpdateGolden is set, the golden file is
+// written instead.
+func AssertGolden(t testing.TB, name string, got []byte) {
+	golden := filepath.Join("testdata", name+".golden")
+	if UpdateGolden {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (set UpdateGolden to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output does not match %s:\ngot:\n%s\nwant:\n%s", golden, got, want)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package logtest provides utilities for testing code that logs.
+//
+// A Recorder captures the records of a Logger so that tests can query
+// them instead of matching formatted output:
+//
+//	logger, rec := logtest.New(log.LevelAll)
+//	doSomething(logger)
+//	logtest.AssertLogged(t, rec, log.LevelError, "connection refused")
+//
+// NewForTest returns a Logger that writes through t.Log, so that its output
+// is attached to the test that produced it and only shown if the test fails
+// or when running with -v.
+package logtest
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-gem/log"
+)
+
+// A Recorder is a log.Handler that keeps every record it handles.
+// It can be used simultaneously from multiple goroutines.
+type Recorder struct {
+	mu      sync.Mutex
+	records []log.Record
+}
+
+// NewRecorder returns an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// New returns a Logger with the given levels that sends its records to
+// a new Recorder. Caller information is always recorded.
+func New(level int) (*log.Logger, *Recorder) {
+	rec := NewRecorder()
+	l := log.New(nil, log.Llongfile, level)
+	l.SetHandler(rec)
+	return l, rec
+}
+
+// Handle implements log.Handler.
+func (r *Recorder) Handle(rec log.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, rec)
+	return nil
+}
+
+// Records returns a copy of the records handled so far, oldest first.
+func (r *Recorder) Records() []log.Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	records := make([]log.Record, len(r.records))
+	copy(records, r.records)
+	return records
+}
+
+// Len returns the number of records handled so far.
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.records)
+}
+
+// Filter returns the records whose level is one of the given levels.
+// Use 0 to select the records written by Print and Panic.
+func (r *Recorder) Filter(level int) []log.Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var records []log.Record
+	for _, rec := range r.records {
+		if rec.Level&level != 0 || rec.Level == level {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+// Contains reports whether any record's message contains substr.
+func (r *Recorder) Contains(substr string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rec := range r.records {
+		if strings.Contains(rec.Message, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset discards all records.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = nil
+}
+
+// AssertLen fails the test if r does not hold exactly n records.
+func AssertLen(t testing.TB, r *Recorder, n int) {
+	if got := r.Len(); got != n {
+		t.Errorf("expected %d records, got %d", n, got)
+	}
+}
+
+// AssertContains fails the test if no record's message contains substr.
+func AssertContains(t testing.TB, r *Recorder, substr string) {
+	if !r.Contains(substr) {
+		t.Errorf("expected a record containing %q", substr)
+	}
+}
+
+// AssertNotContains fails the test if any record's message contains substr.
+func AssertNotContains(t testing.TB, r *Recorder, substr string) {
+	if r.Contains(substr) {
+		t.Errorf("expected no record containing %q", substr)
+	}
+}
+
+// AssertLogged fails the test if no record at one of the given levels
+// has a message containing substr.
+func AssertLogged(t testing.TB, r *Recorder, level int, substr string) {
+	for _, rec := range r.Filter(level) {
+		if strings.Contains(rec.Message, substr) {
+			return
+		}
+	}
+	t.Errorf("expected a record at level %d containing %q", level, substr)
+}
+
+// NewForTest returns a Logger with all levels enabled that writes each
+// line through t.Log. The Logger must not be used after the test returns.
+func NewForTest(t testing.TB) *log.Logger {
+	return log.New(testWriter{t}, log.Lshortfile, log.LevelAll)
+}
+
+// testWriter is an io.Writer that sends each write to t.Log.
+type testWriter struct {
+	t testing.TB
+}
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logtest
+
+import (
+	"bytes"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-gem/log"
+)
+
+func TestRecorder(t *testing.T) {
+	l, rec := New(log.LevelInfo | log.LevelError)
+	l.Debug("ignored")
+	l.Info("hello")
+	l.With("user", "gopher").Errorln("failed")
+	l.Print("plain")
+
+	AssertLen(t, rec, 3)
+	AssertContains(t, rec, "hello")
+	AssertNotContains(t, rec, "ignored")
+	AssertLogged(t, rec, log.LevelError, "failed")
+
+	errs := rec.Filter(log.LevelError)
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error record, got %d", len(errs))
+	}
+	r := errs[0]
+	if r.Message != "failed" {
+		t.Errorf("expected message %q, got %q", "failed", r.Message)
+	}
+	if len(r.Fields) != 1 || r.Fields[0].Key != "user" || r.Fields[0].Value != "gopher" {
+		t.Errorf("unexpected fields %v", r.Fields)
+	}
+	if filepath.Base(r.File) != "logtest_test.go" || r.Line == 0 {
+		t.Errorf("unexpected caller %s:%d", r.File, r.Line)
+	}
+	if n := len(rec.Filter(0)); n != 1 {
+		t.Errorf("expected 1 record without level, got %d", n)
+	}
+
+	rec.Reset()
+	AssertLen(t, rec, 0)
+}
+
+type spy struct {
+	testing.TB
+	logs   []string
+	errors []string
+}
+
+func (s *spy) Log(args ...interface{}) {
+	s.logs = append(s.logs, args[0].(string))
+}
+
+func (s *spy) Errorf(format string, args ...interface{}) {
+	s.errors = append(s.errors, format)
+}
+
+func TestAssertionsFail(t *testing.T) {
+	_, rec := New(log.LevelAll)
+	s := &spy{}
+	AssertLen(s, rec, 1)
+	AssertContains(s, rec, "x")
+	AssertLogged(s, rec, log.LevelInfo, "x")
+	if len(s.errors) != 3 {
+		t.Errorf("expected 3 failures, got %d", len(s.errors))
+	}
+}
+
+func TestNewForTest(t *testing.T) {
+	s := &spy{}
+	l := NewForTest(s)
+	l.Warning("careful")
+	if len(s.logs) != 1 {
+		t.Fatalf("expected 1 log, got %d", len(s.logs))
+	}
+	if !strings.HasPrefix(s.logs[0], "WARN logtest_test.go:") || !strings.HasSuffix(s.logs[0], ": careful") {
+		t.Errorf("unexpected log %q", s.logs[0])
+	}
+}
+
+func init() {
+	flag.BoolVar(&UpdateGolden, "update", false, "update the golden files")
+}
+
+func TestGolden(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewDeterministic(&buf, log.LstdFlags|log.Lmicroseconds|log.Lshortfile, log.LevelAll)
+	l.Info("starting")
+	l.With("user", "gopher", "id", 7).Warning("slow request")
+	l.Errorf("failed after %d retries", 3)
+	AssertGolden(t, "deterministic", buf.Bytes())
+}
@@ -0,0 +1,266 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package otlp converts records to the OpenTelemetry log data model and
+// exports them to a collector with OTLP/HTTP in the JSON encoding.
+//
+// Export is built on package ship, which provides the batching, retries
+// and spooling:
+//
+//	h := otlp.New("http://localhost:4318/v1/logs", []log.Field{{Key: "service.name", Value: "app"}}, nil)
+//	defer h.Close()
+//	logger.SetHandler(h)
+//
+// The fields attached by log.Logger.WithContext, named trace_id and span_id,
+// become the trace context of the log record; the other fields become attributes, as
+// does the caller, under the code.filepath, code.lineno and code.function
+// semantic conventions.
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strconv"
+
+	"github.com/go-gem/log"
+	"github.com/go-gem/log/ship"
+)
+
+// Severity numbers of the OpenTelemetry log data model.
+const (
+	SeverityUnspecified = 0
+	SeverityDebug       = 5
+	SeverityInfo        = 9
+	SeverityWarn        = 13
+	SeverityError       = 17
+	SeverityFatal       = 21
+)
+
+// Severity returns the severity number and text of records at the given
+// level. Records without a level have an unspecified severity.
+func Severity(level int) (int, string) {
+	switch level {
+	case log.LevelDebug:
+		return SeverityDebug, "DEBUG"
+	case log.LevelInfo:
+		return SeverityInfo, "INFO"
+	case log.LevelWarning:
+		return SeverityWarn, "WARN"
+	case log.LevelError:
+		return SeverityError, "ERROR"
+	case log.LevelFatal:
+		return SeverityFatal, "FATAL"
+	}
+	return SeverityUnspecified, ""
+}
+
+// ScopeName is the name of the instrumentation scope of exported records.
+const ScopeName = "github.com/go-gem/log"
+
+// Formatter formats records as OTLP/JSON LogRecord objects, one per line.
+type Formatter struct{}
+
+// Format implements log.Formatter.
+func (Formatter) Format(buf []byte, r *log.Record) []byte {
+	buf = append(buf, `{"timeUnixNano":"`...)
+	buf = strconv.AppendInt(buf, r.Time.UnixNano(), 10)
+	buf = append(buf, `","observedTimeUnixNano":"`...)
+	buf = strconv.AppendInt(buf, r.Time.UnixNano(), 10)
+	buf = append(buf, '"')
+	if number, text := Severity(r.Level); number != SeverityUnspecified {
+		buf = append(buf, `,"severityNumber":`...)
+		buf = strconv.AppendInt(buf, int64(number), 10)
+		buf = append(buf, `,"severityText":"`...)
+		buf = append(buf, text...)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, `,"body":{"stringValue":`...)
+	buf = appendString(buf, r.Message)
+	buf = append(buf, `},"attributes":[`...)
+	n := 0
+	attr := func(key string, value interface{}) {
+		if n > 0 {
+			buf = append(buf, ',')
+		}
+		n++
+		buf = appendAttribute(buf, key, value)
+	}
+	if r.File != "" {
+		attr("code.filepath", r.File)
+		attr("code.lineno", r.Line)
+	}
+	if r.Function != "" {
+		attr("code.function", r.Function)
+	} else if fn := runtime.FuncForPC(r.PC); r.PC != 0 && fn != nil {
+		attr("code.function", fn.Name())
+	}
+	var traceID, spanID string
+	for _, f := range r.Fields {
+		if s, ok := f.Value.(string); ok {
+			if f.Key == log.TraceIDKey && isHex(s, 32) {
+				traceID = s
+				continue
+			}
+			if f.Key == log.SpanIDKey && isHex(s, 16) {
+				spanID = s
+				continue
+			}
+		}
+		attr(f.Key, f.Value)
+	}
+	buf = append(buf, ']')
+	if traceID != "" {
+		buf = append(buf, `,"traceId":"`...)
+		buf = append(buf, traceID...)
+		buf = append(buf, '"')
+	}
+	if spanID != "" {
+		buf = append(buf, `,"spanId":"`...)
+		buf = append(buf, spanID...)
+		buf = append(buf, '"')
+	}
+	return append(buf, "}\n"...)
+}
+
+// appendAttribute appends a KeyValue whose AnyValue has the type that
+// best matches value.
+func appendAttribute(buf []byte, key string, value interface{}) []byte {
+	buf = append(buf, `{"key":`...)
+	buf = appendString(buf, key)
+	buf = append(buf, `,"value":{`...)
+	switch v := value.(type) {
+	case bool:
+		buf = append(buf, `"boolValue":`...)
+		buf = strconv.AppendBool(buf, v)
+	case int:
+		buf = appendInt(buf, int64(v))
+	case int8:
+		buf = appendInt(buf, int64(v))
+	case int16:
+		buf = appendInt(buf, int64(v))
+	case int32:
+		buf = appendInt(buf, int64(v))
+	case int64:
+		buf = appendInt(buf, v)
+	case uint8:
+		buf = appendInt(buf, int64(v))
+	case uint16:
+		buf = appendInt(buf, int64(v))
+	case uint32:
+		buf = appendInt(buf, int64(v))
+	case float32, float64:
+		if b, err := json.Marshal(v); err == nil {
+			buf = append(buf, `"doubleValue":`...)
+			buf = append(buf, b...)
+			break
+		}
+		buf = append(buf, `"stringValue":`...)
+		buf = appendString(buf, fmt.Sprint(v))
+	case error:
+		buf = append(buf, `"stringValue":`...)
+		buf = appendString(buf, v.Error())
+	default:
+		buf = append(buf, `"stringValue":`...)
+		buf = appendString(buf, fmt.Sprint(v))
+	}
+	return append(buf, "}}"...)
+}
+
+// appendInt appends an intValue, which is a string in the JSON encoding
+// of 64-bit integers.
+func appendInt(buf []byte, i int64) []byte {
+	buf = append(buf, `"intValue":"`...)
+	buf = strconv.AppendInt(buf, i, 10)
+	return append(buf, '"')
+}
+
+func appendString(buf []byte, s string) []byte {
+	b, _ := json.Marshal(s) // strings always marshal.
+	return append(buf, b...)
+}
+
+// isHex reports whether s is n lower or upper case hexadecimal digits,
+// not all zero.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	zero := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '0':
+		case '1' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+			zero = false
+		default:
+			return false
+		}
+	}
+	return !zero
+}
+
+type transport struct {
+	endpoint string
+	resource []byte // the Resource object
+	client   *http.Client
+}
+
+// HTTP returns a ship.Transport posting batches of records formatted by
+// Formatter to endpoint as an ExportLogsServiceRequest, with the given
+// resource attributes. A nil client means a client whose requests time
+// out after ship.DialTimeout.
+func HTTP(endpoint string, resource []log.Field, client *http.Client) ship.Transport {
+	if client == nil {
+		client = &http.Client{Timeout: ship.DialTimeout}
+	}
+	res := []byte(`{"attributes":[`)
+	for i, f := range resource {
+		if i > 0 {
+			res = append(res, ',')
+		}
+		res = appendAttribute(res, f.Key, f.Value)
+	}
+	res = append(res, "]}"...)
+	return &transport{endpoint: endpoint, resource: res, client: client}
+}
+
+func (t *transport) Send(batch []byte) error {
+	var body bytes.Buffer
+	body.WriteString(`{"resourceLogs":[{"resource":`)
+	body.Write(t.resource)
+	body.WriteString(`,"scopeLogs":[{"scope":{"name":"` + ScopeName + `"},"logRecords":[`)
+	body.Write(bytes.Replace(bytes.TrimSuffix(batch, []byte{'\n'}), []byte{'\n'}, []byte{','}, -1))
+	body.WriteString(`]}]}]}`)
+	resp, err := t.client.Post(t.endpoint, "application/json", &body)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: %s: %s", t.endpoint, resp.Status)
+	}
+	return nil
+}
+
+func (t *transport) Close() error {
+	return nil
+}
+
+// New returns a ship.Handler exporting records to endpoint, typically
+// http://collector:4318/v1/logs, with the given resource attributes.
+// The Formatter of opts is ignored. opts may be nil.
+func New(endpoint string, resource []log.Field, opts *ship.Options) *ship.Handler {
+	var o ship.Options
+	if opts != nil {
+		o = *opts
+	}
+	o.Formatter = Formatter{}
+	return ship.New(HTTP(endpoint, resource, nil), &o)
+}
@@ -0,0 +1,121 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package otlp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gem/log"
+	"github.com/go-gem/log/ship"
+)
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue"`
+	IntValue    *string  `json:"intValue"`
+	DoubleValue *float64 `json:"doubleValue"`
+	BoolValue   *bool    `json:"boolValue"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type request struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []keyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			LogRecords []struct {
+				TimeUnixNano   string     `json:"timeUnixNano"`
+				SeverityNumber int        `json:"severityNumber"`
+				SeverityText   string     `json:"severityText"`
+				Body           anyValue   `json:"body"`
+				Attributes     []keyValue `json:"attributes"`
+				TraceID        string     `json:"traceId"`
+				SpanID         string     `json:"spanId"`
+			} `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+func TestExport(t *testing.T) {
+	requests := make(chan request, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		requests <- req
+	}))
+	defer ts.Close()
+
+	h := New(ts.URL+"/v1/logs", []log.Field{{Key: "service.name", Value: "app"}}, &ship.Options{MaxLatency: time.Hour})
+	l := log.New(nil, log.Lshortfile, log.LevelAll)
+	l.SetHandler(h)
+	l.SetClock(func() time.Time { return time.Unix(1257894000, 5) })
+	l.With("trace_id", "4bf92f3577b34da6a3ce929d0e0e4736", "span_id", "00f067aa0ba902b7", "n", 7, "ok", true).Error("failed\nbadly")
+	l.With("trace_id", "not hex").Print("plain")
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-requests
+	if len(req.ResourceLogs) != 1 || len(req.ResourceLogs[0].ScopeLogs) != 1 {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	res := req.ResourceLogs[0].Resource.Attributes
+	if len(res) != 1 || res[0].Key != "service.name" || *res[0].Value.StringValue != "app" {
+		t.Errorf("unexpected resource %+v", res)
+	}
+	scope := req.ResourceLogs[0].ScopeLogs[0]
+	if scope.Scope.Name != ScopeName || len(scope.LogRecords) != 2 {
+		t.Fatalf("unexpected scope logs %+v", scope)
+	}
+
+	r := scope.LogRecords[0]
+	if r.TimeUnixNano != "1257894000000000005" || r.SeverityNumber != SeverityError || r.SeverityText != "ERROR" {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if *r.Body.StringValue != "failed\nbadly" {
+		t.Errorf("unexpected body %q", *r.Body.StringValue)
+	}
+	if r.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || r.SpanID != "00f067aa0ba902b7" {
+		t.Errorf("unexpected trace context %s %s", r.TraceID, r.SpanID)
+	}
+	attrs := make(map[string]anyValue)
+	for _, kv := range r.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	if v := attrs["code.filepath"].StringValue; v == nil || !strings.HasSuffix(*v, "otlp_test.go") {
+		t.Errorf("unexpected code.filepath %v", v)
+	}
+	if v := attrs["code.function"].StringValue; v == nil || !strings.HasSuffix(*v, ".TestExport") {
+		t.Errorf("unexpected code.function %v", v)
+	}
+	if v := attrs["n"].IntValue; v == nil || *v != "7" {
+		t.Errorf("unexpected n %v", v)
+	}
+	if v := attrs["ok"].BoolValue; v == nil || !*v {
+		t.Errorf("unexpected ok %v", v)
+	}
+
+	r = scope.LogRecords[1]
+	if r.SeverityNumber != SeverityUnspecified || r.TraceID != "" || len(r.Attributes) != 4 {
+		t.Errorf("unexpected record %+v", r)
+	}
+}
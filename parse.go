@@ -0,0 +1,422 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A ParseError reports a line that cannot be parsed.
+type ParseError struct {
+	Line   string
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return "log: cannot parse " + strconv.Quote(e.Line) + ": " + e.Reason
+}
+
+// ParseLevel returns the level of the given name, as returned by LevelName
+// or written in prefixes, case insensitively: "debug", "info", "warning"
+// or "warn", "error" or "erro", "fatal" or "fata".
+func ParseLevel(name string) (int, error) {
+	switch strings.ToLower(name) {
+	case "debug", "debu":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warning", "warn":
+		return LevelWarning, nil
+	case "error", "erro":
+		return LevelError, nil
+	case "fatal", "fata":
+		return LevelFatal, nil
+	}
+	return 0, errors.New("log: unknown level " + strconv.Quote(name))
+}
+
+// Parse parses a line written by a Logger with the given flags into a
+// record. Times are read in the local time zone unless LUTC is set, and
+// with a zero date unless Ldate is set. Trailing key=value pairs become
+// fields with string values, so a message that ends with such pairs cannot
+// be told apart from fields. The fields are not resolved: a message escaped
+// by Lsanitize or Lsingleline stays escaped. The Function of the record is
+// the function as written, which is fully qualified only if both Lfunc and
+// Lpackage are set. Use a Scanner to read records
+// spanning several lines.
+func Parse(line string, flag int) (Record, error) {
+	r, body, err := parseHeader(line, flag)
+	if err != nil {
+		return r, err
+	}
+	r.Message, r.Fields = splitFields(body)
+	return r, nil
+}
+
+// parseHeader parses the prefix and header of line written with the given
+// flags, and returns the rest of the line.
+func parseHeader(line string, flag int) (Record, string, error) {
+	var r Record
+	s := strings.TrimSuffix(line, "\n")
+	if len(s) >= len(prefixDebug) {
+		if r.Level = prefixLevel(s[:len(prefixDebug)]); r.Level != 0 {
+			s = s[len(prefixDebug):]
+		}
+	}
+	if flag&(Ldate|Ltime|Lmicroseconds) != 0 {
+		var layout string
+		var n int
+		if flag&Ldate != 0 {
+			layout, n = "2006/01/02 ", 11
+		}
+		if flag&Lmicroseconds != 0 {
+			layout, n = layout+"15:04:05.000000 ", n+16
+		} else if flag&Ltime != 0 {
+			layout, n = layout+"15:04:05 ", n+9
+		}
+		if len(s) < n {
+			return r, "", &ParseError{line, "missing time"}
+		}
+		loc := time.Local
+		if flag&LUTC != 0 {
+			loc = time.UTC
+		}
+		t, err := time.ParseInLocation(layout, s[:n], loc)
+		if err != nil {
+			return r, "", &ParseError{line, "bad time"}
+		}
+		r.Time, s = t, s[n:]
+	}
+	if flag&lfile != 0 {
+		i := fileEnd(s)
+		if i < 0 {
+			return r, "", &ParseError{line, "missing file"}
+		}
+		j := strings.LastIndexByte(s[:i], ':')
+		r.File = s[:j]
+		r.Line, _ = strconv.Atoi(s[j+1 : i])
+		s = s[i+len(": "):]
+	}
+	if flag&(Lfunc|Lpackage) != 0 {
+		i := strings.Index(s, ": ")
+		if i <= 0 {
+			return r, "", &ParseError{line, "missing function"}
+		}
+		r.Function, s = s[:i], s[i+len(": "):]
+	}
+	return r, s, nil
+}
+
+// fileEnd returns the index of the ": " that ends the file:line of s,
+// or -1.
+func fileEnd(s string) int {
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] != ':' || s[i+1] != ' ' {
+			continue
+		}
+		j := strings.LastIndexByte(s[:i], ':')
+		if j > 0 && j+1 < i && isDigits(s[j+1:i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// splitFields splits s into its message and the longest sequence of
+// key=value pairs that ends it.
+func splitFields(s string) (string, []Field) {
+	for i := 0; i < len(s); i++ {
+		if s[i] != ' ' {
+			continue
+		}
+		if fields, ok := parseFields(s[i+1:]); ok {
+			return s[:i], fields
+		}
+	}
+	return s, nil
+}
+
+// parseFields parses s as space separated key=value pairs, whose values
+// are quoted as appendValue does.
+func parseFields(s string) ([]Field, bool) {
+	var fields []Field
+	for {
+		i := strings.IndexByte(s, '=')
+		if i <= 0 || strings.ContainsAny(s[:i], " \"") {
+			return nil, false
+		}
+		key := s[:i]
+		s = s[i+1:]
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			q := quotedPrefix(s)
+			v, err := strconv.Unquote(q)
+			if err != nil {
+				return nil, false
+			}
+			value, s = v, s[len(q):]
+		} else {
+			i = strings.IndexByte(s, ' ')
+			if i < 0 {
+				i = len(s)
+			}
+			value = s[:i]
+			if value == "" || strings.IndexByte(value, '"') >= 0 {
+				return nil, false
+			}
+			s = s[i:]
+		}
+		fields = append(fields, Field{Key: key, Value: value})
+		if s == "" {
+			return fields, true
+		}
+		if s[0] != ' ' {
+			return nil, false
+		}
+		s = s[1:]
+	}
+}
+
+// quotedPrefix returns the double quoted string that starts s, up to its
+// first unescaped closing quote, or s if there is none.
+func quotedPrefix(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return s[:i+1]
+		}
+	}
+	return s
+}
+
+// recordField sets the field of r written under key by JSONFormatter and
+// LogfmtFormatter, and reports whether key is one of them.
+func recordField(r *Record, key string, value interface{}) (bool, error) {
+	s, isString := value.(string)
+	switch key {
+	case "time":
+		if !isString {
+			return true, errors.New("time is not a string")
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return true, errors.New("bad time")
+		}
+		r.Time = t
+	case "level":
+		level, err := ParseLevel(s)
+		if !isString || err != nil {
+			return true, errors.New("bad level")
+		}
+		r.Level = level
+	case "msg":
+		if !isString {
+			return true, errors.New("msg is not a string")
+		}
+		r.Message = s
+	case "file":
+		if !isString {
+			return true, errors.New("file is not a string")
+		}
+		r.File = s
+	case "line":
+		if !isString {
+			s = fmtValue(value)
+		}
+		line, err := strconv.Atoi(s)
+		if err != nil {
+			return true, errors.New("bad line")
+		}
+		r.Line = line
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// ParseJSON parses a line written by JSONFormatter into a record. Fields
+// keep their order; numbers are json.Numbers.
+func ParseJSON(line []byte) (Record, error) {
+	var r Record
+	fail := func(reason string) (Record, error) {
+		return r, &ParseError{string(line), reason}
+	}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return fail("not a JSON object")
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return fail(err.Error())
+		}
+		key, _ := t.(string)
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return fail(err.Error())
+		}
+		ok, err := recordField(&r, key, value)
+		if err != nil {
+			return fail(err.Error())
+		}
+		if !ok {
+			r.Fields = append(r.Fields, Field{Key: key, Value: value})
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return fail(err.Error())
+	}
+	return r, nil
+}
+
+// ParseLogfmt parses a line written by LogfmtFormatter into a record.
+// Field values are strings.
+func ParseLogfmt(line string) (Record, error) {
+	var r Record
+	fields, ok := parseFields(strings.TrimSuffix(line, "\n"))
+	if !ok {
+		return r, &ParseError{line, "not logfmt"}
+	}
+	for _, f := range fields {
+		ok, err := recordField(&r, f.Key, f.Value)
+		if err != nil {
+			return r, &ParseError{line, err.Error()}
+		}
+		if !ok {
+			r.Fields = append(r.Fields, f)
+		}
+	}
+	return r, nil
+}
+
+// A Scanner reads the records of a log written by a Logger with the given
+// flags, joining the lines of multi-line messages and of records split by
+// SetMaxLineLength:
+//
+//	s := log.NewScanner(f, log.LstdFlags)
+//	for s.Scan() {
+//		r := s.Record()
+//		...
+//	}
+//	if err := s.Err(); err != nil {
+//		...
+//	}
+//
+// A line starts a record if it has the header of the flags and, if the
+// flags write no header, a level prefix; any other line continues the
+// message of the previous record. A line with the header of the previous
+// record followed by ContinuationMarker continues its last line. A message
+// line that looks like a header, or a continuation, starts a new record.
+type Scanner struct {
+	r        *bufio.Reader
+	flag     int
+	rec      Record
+	body     []byte
+	next     string // line read ahead
+	haveNext bool
+	err      error
+}
+
+// NewScanner returns a Scanner reading from r.
+func NewScanner(r io.Reader, flag int) *Scanner {
+	return &Scanner{r: bufio.NewReader(r), flag: flag}
+}
+
+// Scan advances to the next record, which is then available through
+// Record. It returns false at the end of the input or on an error.
+func (s *Scanner) Scan() bool {
+	line, ok := s.next, s.haveNext
+	if !ok {
+		line, ok = s.readLine()
+	}
+	s.haveNext = false
+	if !ok {
+		return false
+	}
+	r, body, err := s.header(line)
+	if err != nil {
+		// a line before the first record.
+		r, body = Record{}, line
+	}
+	s.body = append(s.body[:0], body...)
+	for {
+		line, ok := s.readLine()
+		if !ok {
+			break
+		}
+		next, body, err := s.header(line)
+		switch {
+		case err != nil:
+			s.body = append(s.body, '\n')
+			s.body = append(s.body, line...)
+			continue
+		case strings.HasPrefix(body, ContinuationMarker) && next.Level == r.Level &&
+			next.Time.Equal(r.Time) && next.File == r.File && next.Line == r.Line && next.Function == r.Function:
+			s.body = append(s.body, body[len(ContinuationMarker):]...)
+			continue
+		}
+		s.next, s.haveNext = line, true
+		break
+	}
+	r.Message, r.Fields = splitFields(string(s.body))
+	s.rec = r
+	return true
+}
+
+// header parses the header of a line that starts a record.
+func (s *Scanner) header(line string) (Record, string, error) {
+	r, body, err := parseHeader(line, s.flag)
+	if err == nil && s.flag&(Ldate|Ltime|Lmicroseconds|lcaller) == 0 && r.Level == 0 {
+		err = &ParseError{line, "no header"}
+	}
+	return r, body, err
+}
+
+// readLine returns the next line without its newline.
+func (s *Scanner) readLine() (string, bool) {
+	if s.err != nil {
+		return "", false
+	}
+	line, err := s.r.ReadString('\n')
+	if err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		if line == "" {
+			return "", false
+		}
+	}
+	return strings.TrimSuffix(line, "\n"), true
+}
+
+// Record returns the record read by the last call to Scan. Its Fields are
+// not reused by later calls.
+func (s *Scanner) Record() Record {
+	return s.rec
+}
+
+// Err returns the first error other than io.EOF met by the Scanner.
+func (s *Scanner) Err() error {
+	return s.err
+}
@@ -0,0 +1,214 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+var parseRecord = Record{
+	Time:    time.Date(2009, 11, 10, 23, 0, 0, 123456000, time.UTC),
+	Level:   LevelError,
+	Message: "failed: a=b c",
+	File:    "/a/b/c/d.go",
+	Line:    23,
+	Fields:  []Field{{"user", "gopher"}, {"err", "no such file"}, {"empty", ""}},
+}
+
+func TestParse(t *testing.T) {
+	flags := []int{
+		0,
+		Ldate | LUTC,
+		Ltime | LUTC,
+		Lmicroseconds | LUTC,
+		LstdFlags | Lmicroseconds | Llongfile | LUTC,
+		Lshortfile,
+	}
+	for _, flag := range flags {
+		line := string(TextFormatter{Flag: flag}.Format(nil, &parseRecord))
+		r, err := Parse(line, flag)
+		if err != nil {
+			t.Errorf("Parse(%q, %#x): %v", line, flag, err)
+			continue
+		}
+		if r.Level != parseRecord.Level || r.Message != parseRecord.Message || !reflect.DeepEqual(r.Fields, parseRecord.Fields) {
+			t.Errorf("Parse(%q, %#x) = %+v", line, flag, r)
+		}
+		if flag&lfile != 0 && (r.File == "" || r.Line != parseRecord.Line) {
+			t.Errorf("Parse(%q, %#x): file %s:%d", line, flag, r.File, r.Line)
+		}
+		want := parseRecord.Time
+		if flag&Ldate == 0 {
+			want = want.AddDate(-2009, -10, -9)
+		}
+		if flag&(Ltime|Lmicroseconds) == 0 {
+			want = want.Truncate(24 * time.Hour)
+		} else if flag&Lmicroseconds == 0 {
+			want = want.Truncate(time.Second)
+		}
+		if flag&(Ldate|Ltime|Lmicroseconds) != 0 && !r.Time.Equal(want) {
+			t.Errorf("Parse(%q, %#x): time %v, want %v", line, flag, r.Time, want)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, test := range []struct {
+		line string
+		flag int
+	}{
+		{"INFO hello\n", Ldate},
+		{"2009/11/10 hello\n", Ldate | Ltime},
+		{"2009/13/10 hello\n", Ldate},
+		{"2009/11/10 hello\n", Ldate | Llongfile},
+	} {
+		if _, err := Parse(test.line, test.flag); err == nil {
+			t.Errorf("Parse(%q, %#x): no error", test.line, test.flag)
+		} else if _, ok := err.(*ParseError); !ok {
+			t.Errorf("Parse(%q, %#x): %T error", test.line, test.flag, err)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	r := parseRecord
+	r.Fields = append(r.Fields, Field{"n", 42}, Field{"ok", true})
+	line := JSONFormatter{}.Format(nil, &r)
+	got, err := ParseJSON(line)
+	if err != nil {
+		t.Fatalf("ParseJSON(%s): %v", line, err)
+	}
+	r.Fields[3].Value = json.Number("42")
+	if !got.Time.Equal(r.Time) || got.Level != r.Level || got.Message != r.Message ||
+		got.File != r.File || got.Line != r.Line || !reflect.DeepEqual(got.Fields, r.Fields) {
+		t.Errorf("ParseJSON(%s) = %+v", line, got)
+	}
+	for _, line := range []string{"", "[]", `{"level":"loud"}`, `{"time":1}`, `{"msg":"a"`} {
+		if _, err := ParseJSON([]byte(line)); err == nil {
+			t.Errorf("ParseJSON(%s): no error", line)
+		}
+	}
+}
+
+func TestParseLogfmt(t *testing.T) {
+	line := string(LogfmtFormatter{}.Format(nil, &parseRecord))
+	got, err := ParseLogfmt(line)
+	if err != nil {
+		t.Fatalf("ParseLogfmt(%q): %v", line, err)
+	}
+	if !got.Time.Equal(parseRecord.Time) || got.Level != parseRecord.Level || got.Message != parseRecord.Message ||
+		got.File != parseRecord.File || got.Line != parseRecord.Line || !reflect.DeepEqual(got.Fields, parseRecord.Fields) {
+		t.Errorf("ParseLogfmt(%q) = %+v", line, got)
+	}
+	for _, line := range []string{"hello", "time=now", `msg="a`, "line=x"} {
+		if _, err := ParseLogfmt(line); err == nil {
+			t.Errorf("ParseLogfmt(%q): no error", line)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for level := LevelDebug; level <= LevelFatal; level <<= 1 {
+		for _, name := range []string{LevelName(level), levelPrefix(level)[:4]} {
+			if got, err := ParseLevel(name); err != nil || got != level {
+				t.Errorf("ParseLevel(%q) = %d, %v, want %d", name, got, err, level)
+			}
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("ParseLevel(loud): no error")
+	}
+}
+
+// TestScannerRoundTrip writes records with the flags of every test of
+// TestAll and reads them back.
+func TestScannerRoundTrip(t *testing.T) {
+	now := time.Date(2009, 11, 10, 23, 4, 5, 123456789, time.Local)
+	long := strings.Repeat("abc ", 40) + "end"
+	for _, test := range tests {
+		var b bytes.Buffer
+		l := New(&b, test.flag, LevelAll)
+		l.SetClock(func() time.Time { return now })
+		_, file, line, _ := runtime.Caller(0)
+		l.Println("hello", 23, "world")
+		l.Errorf("hello %d world", 23)
+		l.With("user", "gopher", "err", "no such file").Info("multi\nline")
+		l.SetMaxLineLength(len(file)+64, true) // room for a few continuations
+		l.With("n", 1).Warning(long)
+		want := []Record{
+			{Message: "hello 23 world", Line: line + 1},
+			{Level: LevelError, Message: "hello 23 world", Line: line + 2},
+			{Level: LevelInfo, Message: "multi\nline", Fields: []Field{{"user", "gopher"}, {"err", "no such file"}}, Line: line + 3},
+			{Level: LevelWarning, Message: long, Fields: []Field{{"n", "1"}}, Line: line + 5},
+		}
+		s := NewScanner(&b, test.flag)
+		for i, w := range want {
+			if !s.Scan() {
+				t.Errorf("flag %#x: record %d missing", test.flag, i)
+				break
+			}
+			r := s.Record()
+			if r.Level != w.Level || r.Message != w.Message || !reflect.DeepEqual(r.Fields, w.Fields) {
+				t.Errorf("flag %#x: record %d = %+v, want %+v", test.flag, i, r, w)
+			}
+			wantFile, wantLine := "", 0
+			if test.flag&Lshortfile != 0 {
+				wantFile, wantLine = "parse_test.go", w.Line
+			} else if test.flag&Llongfile != 0 {
+				wantFile, wantLine = file, w.Line
+			}
+			if r.File != wantFile || r.Line != wantLine {
+				t.Errorf("flag %#x: record %d at %s:%d, want %s:%d", test.flag, i, r.File, r.Line, wantFile, wantLine)
+			}
+			if !r.Time.Equal(headerTime(now, test.flag)) {
+				t.Errorf("flag %#x: record %d time %v, want %v", test.flag, i, r.Time, headerTime(now, test.flag))
+			}
+		}
+		if s.Scan() {
+			t.Errorf("flag %#x: extra record %+v", test.flag, s.Record())
+		}
+		if err := s.Err(); err != nil {
+			t.Errorf("flag %#x: %v", test.flag, err)
+		}
+	}
+}
+
+// headerTime returns t as read back from a header written with flag.
+func headerTime(t time.Time, flag int) time.Time {
+	year, month, day := 0, time.January, 1
+	if flag&Ldate != 0 {
+		year, month, day = t.Date()
+	}
+	hour, min, sec, nsec := 0, 0, 0, 0
+	if flag&(Ltime|Lmicroseconds) != 0 {
+		hour, min, sec = t.Clock()
+	}
+	if flag&Lmicroseconds != 0 {
+		nsec = t.Nanosecond() / 1e3 * 1e3
+	}
+	if flag&(Ldate|Ltime|Lmicroseconds) == 0 {
+		return time.Time{}
+	}
+	return time.Date(year, month, day, hour, min, sec, nsec, t.Location())
+}
+
+func TestScannerStrayLines(t *testing.T) {
+	in := "stray\nmore\nINFO 2009/11/10 hello\nERRO 2009/11/10 ... not a continuation\n"
+	s := NewScanner(strings.NewReader(in), Ldate)
+	var got []string
+	for s.Scan() {
+		got = append(got, s.Record().Message)
+	}
+	want := []string{"stray\nmore", "hello", "... not a continuation"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
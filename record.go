@@ -0,0 +1,121 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// A Field is a key/value pair attached to records by Logger.With.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// A Record is a single logging event as seen by a Handler.
+type Record struct {
+	Time    time.Time
+	Level   int    // one of the Level constants, or 0 for Print and Panic
+	Message string // the message without its trailing newline
+	File    string // empty unless Llongfile, Lshortfile or Lrelfile is set
+	Line    int
+	// Function is the fully qualified name of the function of the
+	// caller, as github.com/a/b.(*Server).handle. It is empty unless one
+	// of Llongfile, Lshortfile, Lrelfile, Lfunc or Lpackage is set.
+	Function string
+	PC       uintptr // program counter of the caller, if Function is set
+	Fields   []Field // must not be modified
+}
+
+// A Handler handles records emitted by a Logger.
+type Handler interface {
+	Handle(r Record) error
+}
+
+// The HandlerFunc type is an adapter to allow the use of
+// ordinary functions as handlers.
+type HandlerFunc func(r Record) error
+
+// Handle calls f(r).
+func (f HandlerFunc) Handle(r Record) error {
+	return f(r)
+}
+
+// appendKeyvals converts alternating keys and values into fields.
+// A key without a value gets the value "(MISSING)".
+func appendKeyvals(fields []Field, keyvals []interface{}) []Field {
+	for i := 0; i < len(keyvals); i += 2 {
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyvals[i])
+		}
+		var value interface{} = "(MISSING)"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		fields = append(fields, Field{Key: key, Value: value})
+	}
+	return fields
+}
+
+type byKey []Field
+
+func (f byKey) Len() int           { return len(f) }
+func (f byKey) Less(i, j int) bool { return f[i].Key < f[j].Key }
+func (f byKey) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+
+// sortFields returns a copy of fields sorted by key. Fields with equal
+// keys keep their order.
+func sortFields(fields []Field) []Field {
+	if len(fields) < 2 {
+		return fields
+	}
+	sorted := make([]Field, len(fields))
+	copy(sorted, fields)
+	sort.Stable(byKey(sorted))
+	return sorted
+}
+
+// appendFields appends fields to buf as space separated key=value pairs,
+// quoting values that contain spaces, quotes, '=', control characters or
+// invalid UTF-8. Keys are sanitized or escaped as the Lsanitize and
+// Lsingleline bits of flag tell.
+func appendFields(buf []byte, fields []Field, flag int) []byte {
+	for _, f := range fields {
+		buf = append(buf, ' ')
+		buf = appendKey(buf, f.Key, flag)
+		buf = append(buf, '=')
+		buf = appendValue(buf, fmtValue(f.Value))
+	}
+	return buf
+}
+
+// appendValue appends v, quoted if needed.
+func appendValue(buf []byte, v string) []byte {
+	if needsQuote(v) {
+		return strconv.AppendQuote(buf, v)
+	}
+	return append(buf, v...)
+}
+
+// fmtValue returns the text representation of a field value.
+func fmtValue(v interface{}) string {
+	return fmt.Sprint(v)
+}
+
+func needsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c == '"' || c == '=' || c == 0x7f {
+			return true
+		}
+	}
+	return needsEscape(s)
+}
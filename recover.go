@@ -0,0 +1,94 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// StackKey is the key of the field holding the stack trace of a panic.
+const StackKey = "stack"
+
+// Recover recovers from a panic and logs it at LevelError with its stack
+// trace. It must be deferred directly:
+//
+//	defer logger.Recover()
+//
+// A panic raised by a Panic method has already been logged by it, and is
+// logged again with its stack trace.
+func (l *Logger) Recover() {
+	if v := recover(); v != nil {
+		l.LogPanic(LevelError, v)
+	}
+}
+
+// RecoverWith is like Recover, but logs at the given level, and panics
+// again with the same value after logging if repanic is true.
+func (l *Logger) RecoverWith(level int, repanic bool) {
+	if v := recover(); v != nil {
+		l.LogPanic(level, v)
+		if repanic {
+			panic(v)
+		}
+	}
+}
+
+// Go calls f in a new goroutine which recovers from panics as Recover does.
+func (l *Logger) Go(f func()) {
+	go func() {
+		defer l.Recover()
+		f()
+	}()
+}
+
+// LogPanic logs v, a value recovered from a panic, at the given level with
+// the stack trace of the panicking goroutine under StackKey. It is meant
+// to be called from a deferred function, and reports the function that
+// panicked as the caller.
+func (l *Logger) LogPanic(level int, v interface{}) {
+	if l.ignore(level) {
+		return
+	}
+	l.With(StackKey, string(debug.Stack())).Output(panicCallDepth()+1, fmt.Sprintf("panic: %v", v), levelPrefix(level))
+}
+
+// panicCallDepth returns the depth, relative to its caller, of the frame
+// of the function that panicked, or 1 if there is no panic in progress.
+func panicCallDepth() int {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs) // skip runtime.Callers and panicCallDepth.
+	frames := runtime.CallersFrames(pcs[:n])
+	depth, panicking := 0, false
+	for {
+		frame, more := frames.Next()
+		if panicking && !strings.HasPrefix(frame.Function, "runtime.") {
+			return depth
+		}
+		if frame.Function == "runtime.gopanic" {
+			panicking = true
+		}
+		if !more {
+			return 1
+		}
+		depth++
+	}
+}
+
+// Recover recovers from a panic and logs it to the standard logger at
+// LevelError with its stack trace. It must be deferred directly.
+func Recover() {
+	if v := recover(); v != nil {
+		std.LogPanic(LevelError, v)
+	}
+}
+
+// Go calls f in a new goroutine which recovers from panics and logs them
+// to the standard logger.
+func Go(f func()) {
+	std.Go(f)
+}
@@ -0,0 +1,115 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func recordingLogger(records chan Record) *Logger {
+	l := New(nil, Lshortfile, LevelAll)
+	l.SetHandler(HandlerFunc(func(r Record) error {
+		records <- r
+		return nil
+	}))
+	return l
+}
+
+func stackOf(r Record) string {
+	for _, f := range r.Fields {
+		if f.Key == StackKey {
+			return f.Value.(string)
+		}
+	}
+	return ""
+}
+
+func TestRecover(t *testing.T) {
+	records := make(chan Record, 2)
+	l := recordingLogger(records)
+	var line int
+	func() {
+		defer l.Recover()
+		var m map[string]int
+		_, _, line, _ = runtime.Caller(0)
+		m["boom"] = 1 // panics on the next line.
+	}()
+	r := <-records
+	if r.Level != LevelError || !strings.HasPrefix(r.Message, "panic: assignment to entry in nil map") {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if filepath.Base(r.File) != "recover_test.go" || r.Line != line+1 {
+		t.Errorf("expected the panicking line as caller, got %s:%d", r.File, r.Line)
+	}
+	if !strings.Contains(stackOf(r), "TestRecover") {
+		t.Errorf("unexpected stack %q", stackOf(r))
+	}
+}
+
+func TestRecoverWith(t *testing.T) {
+	records := make(chan Record, 2)
+	l := recordingLogger(records)
+	defer func() {
+		if v := recover(); v != "again" {
+			t.Errorf("expected to panic again, got %v", v)
+		}
+		if r := <-records; r.Level != LevelFatal || r.Message != "panic: again" {
+			t.Errorf("unexpected record %+v", r)
+		}
+	}()
+	func() {
+		defer l.RecoverWith(LevelFatal, true)
+		panic("again")
+	}()
+}
+
+func TestRecoverPanic(t *testing.T) {
+	records := make(chan Record, 2)
+	l := recordingLogger(records)
+	func() {
+		defer l.Recover()
+		l.Panicf("bad %d", 1)
+	}()
+	if r := <-records; r.Level != 0 || r.Message != "bad 1" {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if r := <-records; r.Level != LevelError || r.Message != "panic: bad 1" || stackOf(r) == "" {
+		t.Errorf("unexpected record %+v", r)
+	}
+}
+
+func TestGo(t *testing.T) {
+	records := make(chan Record, 1)
+	l := recordingLogger(records)
+	l.Go(func() {
+		panic("in goroutine")
+	})
+	if r := <-records; r.Message != "panic: in goroutine" {
+		t.Errorf("unexpected record %+v", r)
+	}
+}
+
+func TestRecoverPanicRecoveredElsewhere(t *testing.T) {
+	records := make(chan Record, 2)
+	l := recordingLogger(records)
+	func() {
+		defer func() { recover() }()
+		l.Panic("x")
+	}()
+	func() {
+		defer l.Recover()
+		panic(fmt.Sprint("x"))
+	}()
+	if r := <-records; r.Message != "x" {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if r := <-records; r.Message != "panic: x" || stackOf(r) == "" {
+		t.Errorf("unexpected record %+v", r)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// RedactedText replaces the sensitive data removed from records.
+const RedactedText = "[REDACTED]"
+
+// A LogValuer controls how its value is logged. Output replaces the value
+// of a field by the result of LogValue, repeatedly if that is a LogValuer
+// too, before the record is redacted and formatted; the printing methods
+// do the same for their arguments before formatting the message.
+type LogValuer interface {
+	LogValue() interface{}
+}
+
+// maxLogValueDepth bounds the resolution of LogValuers returning
+// themselves or each other.
+const maxLogValueDepth = 8
+
+// resolveValue returns the value v is logged as.
+func resolveValue(v interface{}) interface{} {
+	for i := 0; i < maxLogValueDepth; i++ {
+		lv, ok := v.(LogValuer)
+		if !ok {
+			return v
+		}
+		v = lv.LogValue()
+	}
+	return v
+}
+
+// Redacted is a string that is never logged. It is printed as RedactedText
+// by every fmt verb and by every formatter, with or without a Redactor,
+// as a field value or as an argument:
+//
+//	logger.With("ssn", log.Redacted(ssn)).Info("verified")
+type Redacted string
+
+// LogValue implements LogValuer.
+func (Redacted) LogValue() interface{} {
+	return RedactedText
+}
+
+// Format implements fmt.Formatter.
+func (Redacted) Format(f fmt.State, verb rune) {
+	io.WriteString(f, RedactedText)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (Redacted) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + RedactedText + `"`), nil
+}
+
+// DefaultRedactKeys are field names that usually hold credentials.
+var DefaultRedactKeys = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"api_key",
+	"apikey",
+	"authorization",
+	"cookie",
+	"set-cookie",
+}
+
+// A Pattern finds sensitive data in text.
+type Pattern struct {
+	Regexp *regexp.Regexp
+
+	// Replace returns the replacement of a match. A nil Replace means
+	// RedactedText; returning the match unchanged keeps it.
+	Replace func(match string) string
+}
+
+// Built-in patterns.
+var (
+	// PAN matches payment card numbers of 13 to 19 digits, optionally
+	// grouped by spaces or dashes, that pass the Luhn check.
+	PAN = &Pattern{
+		Regexp: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		Replace: func(match string) string {
+			if !luhn(match) {
+				return match
+			}
+			return RedactedText
+		},
+	}
+
+	// BearerToken matches the token of a bearer authorization.
+	BearerToken = &Pattern{
+		Regexp: regexp.MustCompile(`(?i)\b(bearer)\s+[A-Za-z0-9\-._~+/]+=*`),
+		Replace: func(match string) string {
+			return match[:len("bearer")] + " " + RedactedText
+		},
+	}
+
+	// Email matches email addresses.
+	Email = &Pattern{
+		Regexp: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+	}
+)
+
+// luhn reports whether the digits of s pass the Luhn check, ignoring
+// spaces and dashes.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// A Redactor removes sensitive data from records before they are passed
+// to a Handler or formatted. It replaces the values of the fields with
+// denied names by RedactedText, and the matches of its patterns in the
+// message and in the field values. A Redactor is safe for concurrent use.
+type Redactor struct {
+	keys     map[string]bool
+	patterns []*Pattern
+}
+
+// NewRedactor returns a Redactor that denies the given field names, case
+// insensitively, and scrubs the matches of the given patterns:
+//
+//	log.NewRedactor(log.DefaultRedactKeys, log.PAN, log.BearerToken, log.Email)
+func NewRedactor(keys []string, patterns ...*Pattern) *Redactor {
+	r := &Redactor{keys: make(map[string]bool, len(keys))}
+	for _, k := range keys {
+		r.keys[strings.ToLower(k)] = true
+	}
+	r.patterns = append(r.patterns, patterns...)
+	return r
+}
+
+// RedactString returns s with the matches of the patterns replaced.
+func (r *Redactor) RedactString(s string) string {
+	for _, p := range r.patterns {
+		if p.Replace == nil {
+			s = p.Regexp.ReplaceAllLiteralString(s, RedactedText)
+		} else {
+			s = p.Regexp.ReplaceAllStringFunc(s, p.Replace)
+		}
+	}
+	return s
+}
+
+// Redact redacts the message and the fields of rec. Fields are copied
+// rather than modified.
+func (r *Redactor) Redact(rec *Record) {
+	rec.Message = r.RedactString(rec.Message)
+	var fields []Field
+	for i, f := range rec.Fields {
+		v, ok := r.redactField(f)
+		if !ok {
+			continue
+		}
+		if fields == nil {
+			fields = make([]Field, len(rec.Fields))
+			copy(fields, rec.Fields)
+		}
+		fields[i].Value = v
+	}
+	if fields != nil {
+		rec.Fields = fields
+	}
+}
+
+// redactField returns the redacted value of f, and whether it differs
+// from f.Value. Values of every type are scrubbed as they are formatted,
+// and byte slices as text, so a value with a match is replaced by its
+// redacted text.
+func (r *Redactor) redactField(f Field) (interface{}, bool) {
+	if r.keys[strings.ToLower(f.Key)] {
+		return RedactedText, true
+	}
+	if _, ok := f.Value.(Redacted); ok || f.Value == nil {
+		return nil, false
+	}
+	var s string
+	if b, ok := f.Value.([]byte); ok {
+		s = string(b)
+	} else {
+		s = fmtValue(f.Value)
+	}
+	if rs := r.RedactString(s); rs != s {
+		return rs, true
+	}
+	return nil, false
+}
+
+// SetRedactor sets the Redactor of the logger. A nil Redactor disables
+// redaction; Redacted values and LogValuers are still resolved.
+func (l *Logger) SetRedactor(r *Redactor) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redactor = r
+}
+
+// resolveArgs returns args with the LogValuers resolved. args is copied
+// only if it contains a LogValuer.
+func resolveArgs(args []interface{}) []interface{} {
+	var resolved []interface{}
+	for i, v := range args {
+		if _, ok := v.(LogValuer); !ok {
+			continue
+		}
+		if resolved == nil {
+			resolved = make([]interface{}, len(args))
+			copy(resolved, args)
+		}
+		resolved[i] = resolveValue(v)
+	}
+	if resolved == nil {
+		return args
+	}
+	return resolved
+}
+
+// resolveFields returns fields with the values of LogValuers resolved.
+// fields is copied only if it contains a LogValuer.
+func resolveFields(fields []Field) []Field {
+	var resolved []Field
+	for i, f := range fields {
+		if _, ok := f.Value.(LogValuer); !ok {
+			continue
+		}
+		if resolved == nil {
+			resolved = make([]Field, len(fields))
+			copy(resolved, fields)
+		}
+		resolved[i].Value = resolveValue(f.Value)
+	}
+	if resolved == nil {
+		return fields
+	}
+	return resolved
+}
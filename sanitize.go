@@ -0,0 +1,102 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// escapedRune reports whether r must be escaped to keep a line from being
+// split or from controlling a terminal: the C0 and C1 control characters,
+// DEL, the Unicode line and paragraph separators and the bidirectional
+// formatting characters.
+func escapedRune(r rune) bool {
+	switch {
+	case r < 0x20, r >= 0x7f && r <= 0x9f:
+		return true
+	case r == 0x2028, r == 0x2029:
+		return true
+	case r >= 0x202a && r <= 0x202e, r >= 0x2066 && r <= 0x2069:
+		return true
+	}
+	return false
+}
+
+// needsEscape reports whether s contains a rune that appendSanitized
+// escapes, or invalid UTF-8.
+func needsEscape(s string) bool {
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c < 0x20 || c == 0x7f {
+				return true
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 || escapedRune(r) {
+			return true
+		}
+		i += size
+	}
+	return false
+}
+
+// appendSanitized appends s to buf with the runes reported by escapedRune
+// and the bytes of invalid UTF-8 escaped as Go does in strings: \n, \r and
+// \t, \x1b for the other bytes, \u2028 for the other runes. The result is
+// valid UTF-8 that holds no line break nor terminal control sequence.
+func appendSanitized(buf []byte, s string) []byte {
+	for i := 0; i < len(s); {
+		r, size := rune(s[i]), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRuneInString(s[i:])
+		}
+		switch {
+		case r == utf8.RuneError && size == 1:
+			buf = appendHexEscape(buf, 'x', uint32(s[i]), 2)
+		case r == '\n':
+			buf = append(buf, `\n`...)
+		case r == '\r':
+			buf = append(buf, `\r`...)
+		case r == '\t':
+			buf = append(buf, `\t`...)
+		case r < 0x20 || r == 0x7f:
+			buf = appendHexEscape(buf, 'x', uint32(r), 2)
+		case escapedRune(r):
+			buf = appendHexEscape(buf, 'u', uint32(r), 4)
+		default:
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return buf
+}
+
+// appendHexEscape appends a backslash, verb and the n low hex digits of v.
+func appendHexEscape(buf []byte, verb byte, v uint32, n int) []byte {
+	buf = append(buf, '\\', verb)
+	for shift := uint(4 * (n - 1)); ; shift -= 4 {
+		buf = append(buf, hexDigits[v>>shift&0xf])
+		if shift == 0 {
+			return buf
+		}
+	}
+}
+
+// appendKey appends the field key k, sanitized or with its newlines
+// escaped as the Lsanitize and Lsingleline bits of flag tell.
+func appendKey(buf []byte, k string, flag int) []byte {
+	switch {
+	case flag&Lsanitize != 0:
+		return appendSanitized(buf, k)
+	case flag&Lsingleline != 0:
+		return appendSingleLine(buf, k)
+	}
+	return append(buf, k...)
+}
@@ -0,0 +1,49 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var fuzzSeeds = []string{"", "hello", "a\nERRO b", "\r\n", "\x1b[31m", "\xff\xfe", "\u2028", "k=v \"q\""}
+
+func FuzzLoggerSanitize(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add(s, s, s)
+	}
+	f.Fuzz(func(t *testing.T, msg, key, value string) {
+		var b bytes.Buffer
+		l := New(&b, LstdFlags|Lshortfile|Lsanitize, LevelAll)
+		l.With(key, value).Error(msg)
+		checkLine(t, b.String())
+	})
+}
+
+func FuzzStructuredFormatters(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add(s, s, s)
+	}
+	f.Fuzz(func(t *testing.T, msg, key, value string) {
+		r := &Record{
+			Time:    time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC),
+			Level:   LevelWarning,
+			Message: msg,
+			Fields:  []Field{{Key: key, Value: value}},
+		}
+		out := string(JSONFormatter{}.Format(nil, r))
+		checkLine(t, out)
+		if !json.Valid([]byte(out)) {
+			t.Fatalf("invalid JSON: %q", out)
+		}
+		checkLine(t, string(LogfmtFormatter{}.Format(nil, r)))
+	})
+}
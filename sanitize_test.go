@@ -0,0 +1,64 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var sanitizeTests = []struct {
+	in, out string
+}{
+	{"plain text", "plain text"},
+	{"line\nERRO forged", `line\nERRO forged`},
+	{"a\r\tb", `a\r\tb`},
+	{"\x1b[31mred\x1b[0m", `\x1b[31mred\x1b[0m`},
+	{"del\x7f", `del\x7f`},
+	{"bad \xff utf-8", `bad \xff utf-8`},
+	{"c1 \u0085 next line", `c1 \u0085 next line`},
+	{"sep \u2028 \u2029", `sep \u2028 \u2029`},
+	{"bidi \u202e \u2066", `bidi \u202e \u2066`},
+	{"unicode é 世界", "unicode é 世界"},
+	{`back\slash`, `back\slash`},
+}
+
+func TestSanitize(t *testing.T) {
+	for _, tt := range sanitizeTests {
+		if got := string(appendSanitized(nil, tt.in)); got != tt.out {
+			t.Errorf("appendSanitized(%q) = %q, want %q", tt.in, got, tt.out)
+		}
+		if needsEscape(tt.in) != (tt.in != tt.out) {
+			t.Errorf("needsEscape(%q) = %v", tt.in, !(tt.in != tt.out))
+		}
+	}
+}
+
+func TestSanitizeFlag(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b, Lsanitize, LevelAll)
+	l.With("k\nERRO x", "v\xff").Info("msg\n\x1b[2J")
+	if want := `INFO msg\n\x1b[2J k\nERRO x="v\xff"` + "\n"; b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
+
+// checkLine fails unless out is a single valid UTF-8 line without any
+// escaped rune.
+func checkLine(t *testing.T, out string) {
+	if strings.Count(out, "\n") != 1 || !strings.HasSuffix(out, "\n") {
+		t.Fatalf("not a single line: %q", out)
+	}
+	if !utf8.ValidString(out) {
+		t.Fatalf("invalid UTF-8: %q", out)
+	}
+	for _, r := range strings.TrimSuffix(out, "\n") {
+		if escapedRune(r) {
+			t.Fatalf("unescaped %U in %q", r, out)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ship provides a log.Handler that ships records to a remote
+// collector over TCP, UDP or HTTP.
+//
+// Records are formatted, one per line, into batches which are sent when
+// they hold enough records or bytes, or when their oldest record has waited
+// long enough. Failed sends are retried with exponential backoff. Batches
+// which still cannot be sent are written to an on-disk spool, if one is
+// configured, and replayed once the remote accepts batches again, so
+// replayed records may arrive after newer ones.
+//
+//	h := ship.New(ship.HTTP("http://collector:8080/logs", nil), &ship.Options{SpoolDir: "/var/spool/app"})
+//	defer h.Close()
+//	logger.SetHandler(h)
+package ship
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gem/log"
+)
+
+// Options configure a Handler. Zero values select the defaults.
+type Options struct {
+	BatchCount    int           // records per batch; default 100
+	BatchBytes    int           // bytes per batch; default 1 MiB
+	MaxLatency    time.Duration // time a record may wait in a batch; default 1s
+	MaxRetries    int           // retries of a failed send; default 3, negative for none
+	MinBackoff    time.Duration // delay before the first retry; default 100ms
+	MaxBackoff    time.Duration // maximum delay between retries; default 10s
+	QueueSize     int           // batches waiting to be sent; default 16
+	SpoolDir      string        // directory of the spool; if empty, failed batches are dropped
+	SpoolMaxBytes int64         // size of the spool above which the oldest batches are dropped; default 64 MiB
+	Formatter     log.Formatter // default log.JSONFormatter
+}
+
+func (o *Options) setDefaults() {
+	if o.BatchCount <= 0 {
+		o.BatchCount = 100
+	}
+	if o.BatchBytes <= 0 {
+		o.BatchBytes = 1 << 20
+	}
+	if o.MaxLatency <= 0 {
+		o.MaxLatency = time.Second
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = 3
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = 100 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 10 * time.Second
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 16
+	}
+	if o.SpoolMaxBytes <= 0 {
+		o.SpoolMaxBytes = 64 << 20
+	}
+	if o.Formatter == nil {
+		o.Formatter = log.JSONFormatter{}
+	}
+}
+
+// Stats holds the counters of a Handler.
+type Stats struct {
+	Records  uint64 // records handled
+	Batches  uint64 // batches sent, including replayed ones
+	Bytes    uint64 // bytes sent
+	Retries  uint64 // retried sends
+	Spooled  uint64 // batches written to the spool
+	Replayed uint64 // batches replayed from the spool
+	Dropped  uint64 // records lost
+}
+
+// A batch is a sequence of formatted records, one per line.
+type batch struct {
+	data  []byte
+	count int
+	done  chan struct{} // if not nil, closed once the batch is dealt with
+}
+
+// A Handler is a log.Handler that ships records to a Transport.
+// A Handler can be used simultaneously from multiple goroutines.
+type Handler struct {
+	// stats is accessed atomically. It comes first so that its counters
+	// are 64-bit aligned on 32-bit platforms, as sync/atomic requires.
+	stats Stats
+
+	opts      Options
+	transport Transport
+	spool     *spool
+	queue     chan batch
+	quit      chan struct{}
+	stopped   chan struct{}
+
+	mu      sync.Mutex // protects the following fields
+	pending batch
+	gen     int // incremented whenever pending is taken
+	closed  bool
+
+	flushing sync.WaitGroup // Flush calls waiting outside of mu
+}
+
+// ErrClosed is returned by Handle, Flush and Close after Close.
+var ErrClosed = errors.New("ship: handler is closed")
+
+// New returns a Handler that sends batches with t. opts may be nil.
+// The Handler must be closed to send the last records.
+func New(t Transport, opts *Options) *Handler {
+	h := &Handler{
+		transport: t,
+		quit:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	h.opts.setDefaults()
+	if h.opts.SpoolDir != "" {
+		h.spool = &spool{dir: h.opts.SpoolDir, max: h.opts.SpoolMaxBytes}
+	}
+	h.queue = make(chan batch, h.opts.QueueSize)
+	go h.run()
+	return h
+}
+
+// Handle implements log.Handler.
+func (h *Handler) Handle(r log.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		atomic.AddUint64(&h.stats.Dropped, 1)
+		return ErrClosed
+	}
+	atomic.AddUint64(&h.stats.Records, 1)
+	if h.pending.count == 0 {
+		gen := h.gen
+		time.AfterFunc(h.opts.MaxLatency, func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if h.gen == gen && !h.closed {
+				h.enqueue()
+			}
+		})
+	}
+	h.pending.data = h.opts.Formatter.Format(h.pending.data, &r)
+	h.pending.count++
+	if h.pending.count >= h.opts.BatchCount || len(h.pending.data) >= h.opts.BatchBytes {
+		h.enqueue()
+	}
+	return nil
+}
+
+// take removes the pending batch. h.mu must be held.
+func (h *Handler) take() batch {
+	b := h.pending
+	h.pending = batch{}
+	h.gen++
+	return b
+}
+
+// enqueue passes the pending batch to the sending goroutine. If the queue
+// is full, the batch is spooled or dropped. h.mu must be held.
+func (h *Handler) enqueue() {
+	b := h.take()
+	if b.count == 0 {
+		return
+	}
+	select {
+	case h.queue <- b:
+	default:
+		h.fail(b)
+	}
+}
+
+// wait passes b, taken from pending, to the sending goroutine, waiting for
+// room in the queue, then until the batch is dealt with. h.mu must not be
+// held, so that Handle does not block while the queue is full.
+func (h *Handler) wait(b batch) {
+	b.done = make(chan struct{})
+	h.queue <- b
+	<-b.done
+}
+
+// Flush sends the pending records and waits until every batch handled so
+// far has been sent, spooled or dropped.
+func (h *Handler) Flush() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return ErrClosed
+	}
+	b := h.take()
+	h.flushing.Add(1)
+	h.mu.Unlock()
+	defer h.flushing.Done()
+	h.wait(b)
+	return nil
+}
+
+// Close flushes the Handler, stops it and closes its Transport.
+func (h *Handler) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return ErrClosed
+	}
+	h.closed = true
+	b := h.take()
+	h.mu.Unlock()
+	h.wait(b)
+	h.flushing.Wait()
+	close(h.quit)
+	<-h.stopped
+	return h.transport.Close()
+}
+
+// Stats returns a snapshot of the counters of the Handler.
+func (h *Handler) Stats() Stats {
+	return Stats{
+		Records:  atomic.LoadUint64(&h.stats.Records),
+		Batches:  atomic.LoadUint64(&h.stats.Batches),
+		Bytes:    atomic.LoadUint64(&h.stats.Bytes),
+		Retries:  atomic.LoadUint64(&h.stats.Retries),
+		Spooled:  atomic.LoadUint64(&h.stats.Spooled),
+		Replayed: atomic.LoadUint64(&h.stats.Replayed),
+		Dropped:  atomic.LoadUint64(&h.stats.Dropped),
+	}
+}
+
+// run sends the queued batches until quit is closed.
+func (h *Handler) run() {
+	defer close(h.stopped)
+	ticker := time.NewTicker(h.opts.MaxLatency)
+	defer ticker.Stop()
+	for {
+		select {
+		case b := <-h.queue:
+			if b.count > 0 {
+				if h.send(b.data, h.opts.MaxRetries) {
+					h.replay()
+				} else {
+					h.fail(b)
+				}
+			}
+			if b.done != nil {
+				close(b.done)
+			}
+		case <-ticker.C:
+			// the remote may be back.
+			if h.spool != nil && !h.spool.empty() {
+				h.replay()
+			}
+		case <-h.quit:
+			return
+		}
+	}
+}
+
+// send sends data, retrying with exponential backoff, and reports whether
+// it succeeded.
+func (h *Handler) send(data []byte, retries int) bool {
+	backoff := h.opts.MinBackoff
+	for i := 0; ; i++ {
+		if err := h.transport.Send(data); err == nil {
+			atomic.AddUint64(&h.stats.Batches, 1)
+			atomic.AddUint64(&h.stats.Bytes, uint64(len(data)))
+			return true
+		}
+		if i >= retries {
+			return false
+		}
+		atomic.AddUint64(&h.stats.Retries, 1)
+		select {
+		case <-time.After(backoff):
+		case <-h.quit:
+			return false
+		}
+		if backoff *= 2; backoff > h.opts.MaxBackoff {
+			backoff = h.opts.MaxBackoff
+		}
+	}
+}
+
+// fail spools b, or drops it if there is no spool.
+func (h *Handler) fail(b batch) {
+	if h.spool != nil {
+		dropped, err := h.spool.push(b.data)
+		if err == nil {
+			atomic.AddUint64(&h.stats.Spooled, 1)
+			atomic.AddUint64(&h.stats.Dropped, uint64(dropped))
+			return
+		}
+	}
+	atomic.AddUint64(&h.stats.Dropped, uint64(b.count))
+}
+
+// replay sends the spooled batches, oldest first, until one fails.
+func (h *Handler) replay() {
+	if h.spool == nil {
+		return
+	}
+	for {
+		name, data, err := h.spool.peek()
+		if err != nil || name == "" {
+			return
+		}
+		if !h.send(data, 0) {
+			return
+		}
+		h.spool.remove(name)
+		atomic.AddUint64(&h.stats.Replayed, 1)
+	}
+}
+
+// countLines returns the number of records in a batch.
+func countLines(data []byte) int {
+	return bytes.Count(data, []byte{'\n'})
+}
@@ -0,0 +1,311 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ship
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gem/log"
+)
+
+// fakeTransport records batches, failing while failures is positive or
+// down is true.
+type fakeTransport struct {
+	mu       sync.Mutex
+	batches  []string
+	failures int
+	down     bool
+}
+
+func (t *fakeTransport) Send(batch []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.down {
+		return errors.New("down")
+	}
+	if t.failures > 0 {
+		t.failures--
+		return errors.New("failure")
+	}
+	t.batches = append(t.batches, string(batch))
+	return nil
+}
+
+func (t *fakeTransport) Close() error { return nil }
+
+func (t *fakeTransport) setDown(down bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.down = down
+}
+
+func (t *fakeTransport) lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.Split(strings.TrimSuffix(strings.Join(t.batches, ""), "\n"), "\n")
+}
+
+func newLogger(h log.Handler) *log.Logger {
+	l := log.New(nil, 0, log.LevelAll)
+	l.SetHandler(h)
+	return l
+}
+
+func TestBatching(t *testing.T) {
+	tr := &fakeTransport{}
+	h := New(tr, &Options{BatchCount: 2, MaxLatency: time.Hour, Formatter: log.TextFormatter{}})
+	l := newLogger(h)
+	l.Info("one")
+	l.Info("two")
+	l.Info("three")
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.batches) != 2 || tr.batches[0] != "INFO one\nINFO two\n" || tr.batches[1] != "INFO three\n" {
+		t.Errorf("unexpected batches %q", tr.batches)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Handle(log.Record{}); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	stats := h.Stats()
+	if stats.Records != 3 || stats.Batches != 2 || stats.Dropped != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tr := &fakeTransport{failures: 2}
+	h := New(tr, &Options{MinBackoff: time.Millisecond, MaxRetries: 3})
+	defer h.Close()
+	newLogger(h).Info("hello")
+	h.Flush()
+	if stats := h.Stats(); stats.Batches != 1 || stats.Retries != 2 || stats.Dropped != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ship")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tr := &fakeTransport{down: true}
+	h := New(tr, &Options{MaxRetries: -1, MaxLatency: time.Hour, SpoolDir: dir, Formatter: log.TextFormatter{}})
+	defer h.Close()
+	l := newLogger(h)
+	l.Info("spooled")
+	h.Flush()
+	if files, _ := h.spool.files(); len(files) != 1 {
+		t.Fatalf("expected 1 spooled batch, got %d", len(files))
+	}
+
+	tr.setDown(false)
+	l.Info("sent")
+	h.Flush()
+	if lines := tr.lines(); len(lines) != 2 || lines[0] != "INFO sent" || lines[1] != "INFO spooled" {
+		t.Errorf("unexpected lines %q", lines)
+	}
+	if stats := h.Stats(); stats.Spooled != 1 || stats.Replayed != 1 || stats.Dropped != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if !h.spool.empty() {
+		t.Error("expected the spool to be empty")
+	}
+}
+
+func TestSpoolMaxBytes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ship")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := &spool{dir: dir, max: 10}
+	s.push([]byte("a\nb\n"))
+	s.push([]byte("c\nd\n"))
+	dropped, err := s.push([]byte("e\nf\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 2 {
+		t.Errorf("expected 2 dropped records, got %d", dropped)
+	}
+	if _, data, _ := s.peek(); string(data) != "c\nd\n" {
+		t.Errorf("unexpected oldest batch %q", data)
+	}
+}
+
+func TestTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	h := New(TCP(ln.Addr().String()), &Options{Formatter: log.TextFormatter{}})
+	l := newLogger(h)
+	l.Warning("one")
+	l.Error("two")
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"WARN one", "ERRO two"} {
+		select {
+		case got := <-lines:
+			if got != want {
+				t.Errorf("got %q; want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer pc.Close()
+	h := New(UDP(pc.LocalAddr().String()), &Options{Formatter: log.TextFormatter{}})
+	l := newLogger(h)
+	l.Info("one")
+	l.Info("two")
+	h.Close()
+	b := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []string{"INFO one\n", "INFO two\n"} {
+		n, _, err := pc.ReadFrom(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b[:n]) != want {
+			t.Errorf("got %q; want %q", b[:n], want)
+		}
+	}
+}
+
+func TestHTTPMaxLatency(t *testing.T) {
+	bodies := make(chan string, 10)
+	status := http.StatusServiceUnavailable
+	var mu sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			b, _ := ioutil.ReadAll(r.Body)
+			bodies <- r.Header.Get("Content-Type") + " " + string(b)
+		}
+		status = http.StatusOK
+	}))
+	defer ts.Close()
+
+	h := New(HTTP(ts.URL, nil), &Options{MaxLatency: 10 * time.Millisecond, MinBackoff: time.Millisecond})
+	defer h.Close()
+	newLogger(h).Info("hello")
+	select {
+	case got := <-bodies:
+		if !strings.HasPrefix(got, `application/x-ndjson {"time":`) || !strings.HasSuffix(got, `"msg":"hello"}`+"\n") {
+			t.Errorf("unexpected body %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	if stats := h.Stats(); stats.Retries != 1 {
+		t.Errorf("expected 1 retry, got %+v", stats)
+	}
+}
+
+func TestHTTPTimeout(t *testing.T) {
+	hang := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer ts.Close()
+	defer close(hang)
+	defer func(d time.Duration) { DialTimeout = d }(DialTimeout)
+	DialTimeout = 50 * time.Millisecond
+
+	done := make(chan error)
+	go func() { done <- HTTP(ts.URL, nil).Send([]byte("{}\n")) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected a timeout error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send blocked on a hung server")
+	}
+}
+
+// blockingTransport blocks sends until release is closed.
+type blockingTransport struct {
+	release chan struct{}
+}
+
+func (t *blockingTransport) Send(batch []byte) error {
+	<-t.release
+	return nil
+}
+
+func (t *blockingTransport) Close() error { return nil }
+
+func TestHandleDuringFlush(t *testing.T) {
+	tr := &blockingTransport{release: make(chan struct{})}
+	h := New(tr, &Options{BatchCount: 1, QueueSize: 1, MaxLatency: time.Hour})
+	l := newLogger(h)
+	l.Info("sending")
+	l.Info("queued")
+	flushed := make(chan struct{})
+	go func() {
+		h.Flush()
+		close(flushed)
+	}()
+	handled := make(chan struct{})
+	go func() {
+		l.Info("handled")
+		close(handled)
+	}()
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Handle blocked by a waiting Flush")
+	}
+	close(tr.release)
+	<-flushed
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := h.Stats(); stats.Records != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
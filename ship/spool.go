@@ -0,0 +1,111 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ship
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spool is an on-disk queue of batches, one file per batch. File names
+// sort in the order the batches were pushed.
+type spool struct {
+	mu  sync.Mutex
+	dir string
+	max int64
+	seq int
+}
+
+const spoolExt = ".batch"
+
+// files returns the names of the spooled batches, oldest first.
+func (s *spool) files() ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	files := infos[:0]
+	for _, info := range infos {
+		if strings.HasSuffix(info.Name(), spoolExt) {
+			files = append(files, info)
+		}
+	}
+	return files, nil // ReadDir sorts by name.
+}
+
+// push writes a batch to the spool, dropping the oldest batches if the
+// spool grows too large. It returns the number of records dropped.
+func (s *spool) push(data []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return 0, err
+	}
+	s.seq++
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), s.seq%1000000, spoolExt)
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+
+	files, err := s.files()
+	if err != nil {
+		return 0, nil
+	}
+	var size int64
+	for _, f := range files {
+		size += f.Size()
+	}
+	dropped := 0
+	for i := 0; size > s.max && i < len(files)-1; i++ {
+		path := filepath.Join(s.dir, files[i].Name())
+		if old, err := ioutil.ReadFile(path); err == nil {
+			dropped += countLines(old)
+		}
+		os.Remove(path)
+		size -= files[i].Size()
+	}
+	return dropped, nil
+}
+
+// peek returns the name and content of the oldest batch, or an empty
+// name if the spool is empty.
+func (s *spool) peek() (string, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, err := s.files()
+	if err != nil || len(files) == 0 {
+		return "", nil, err
+	}
+	name := files[0].Name()
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	return name, data, err
+}
+
+func (s *spool) remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	os.Remove(filepath.Join(s.dir, name))
+}
+
+func (s *spool) empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, _ := s.files()
+	return len(files) == 0
+}
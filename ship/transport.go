@@ -0,0 +1,140 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ship
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// A Transport sends batches of records, one per line, to a remote
+// collector. Send is never called concurrently.
+type Transport interface {
+	Send(batch []byte) error
+	Close() error
+}
+
+// DialTimeout is the timeout used by the TCP and UDP transports to
+// connect, by the TCP transport to write a batch, and by the default
+// client of the HTTP transport for a request.
+var DialTimeout = 10 * time.Second
+
+type streamTransport struct {
+	network, addr string
+	conn          net.Conn
+}
+
+// TCP returns a Transport writing batches to a TCP connection to addr.
+// It connects lazily and reconnects after a failure.
+func TCP(addr string) Transport {
+	return &streamTransport{network: "tcp", addr: addr}
+}
+
+func (t *streamTransport) Send(batch []byte) error {
+	if t.conn == nil {
+		conn, err := net.DialTimeout(t.network, t.addr, DialTimeout)
+		if err != nil {
+			return err
+		}
+		t.conn = conn
+	}
+	t.conn.SetWriteDeadline(time.Now().Add(DialTimeout))
+	if _, err := t.conn.Write(batch); err != nil {
+		t.conn.Close()
+		t.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (t *streamTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
+
+type packetTransport struct {
+	addr string
+	conn net.Conn
+}
+
+// UDP returns a Transport sending each record of a batch as a datagram
+// to addr.
+func UDP(addr string) Transport {
+	return &packetTransport{addr: addr}
+}
+
+func (t *packetTransport) Send(batch []byte) error {
+	if t.conn == nil {
+		conn, err := net.DialTimeout("udp", t.addr, DialTimeout)
+		if err != nil {
+			return err
+		}
+		t.conn = conn
+	}
+	for len(batch) > 0 {
+		line := batch
+		if i := bytes.IndexByte(batch, '\n'); i >= 0 {
+			line = batch[:i+1]
+		}
+		if _, err := t.conn.Write(line); err != nil {
+			t.conn.Close()
+			t.conn = nil
+			return err
+		}
+		batch = batch[len(line):]
+	}
+	return nil
+}
+
+func (t *packetTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
+
+type httpTransport struct {
+	url    string
+	client *http.Client
+}
+
+// HTTP returns a Transport posting each batch to url with the content
+// type application/x-ndjson. A nil client means a client whose requests
+// time out after DialTimeout, so that a hung server cannot block the
+// Handler. Any response status other than 2xx is an error.
+func HTTP(url string, client *http.Client) Transport {
+	if client == nil {
+		client = &http.Client{Timeout: DialTimeout}
+	}
+	return &httpTransport{url: url, client: client}
+}
+
+func (t *httpTransport) Send(batch []byte) error {
+	resp, err := t.client.Post(t.url, "application/x-ndjson", bytes.NewReader(batch))
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("ship: %s: %s", t.url, resp.Status)
+	}
+	return nil
+}
+
+func (t *httpTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// numLevels is the number of predefined levels, plus one for the records
+// without a level.
+const numLevels = 6
+
+// levelIndex returns the index of level in the counters: 0 for the records
+// without a level, and 1 to 5 for LevelDebug to LevelFatal.
+func levelIndex(level int) int {
+	for i := 1; i < numLevels; i++ {
+		if level == 1<<uint(i-1) {
+			return i
+		}
+	}
+	return 0
+}
+
+// counters are the statistics of the loggers with the same name. They
+// are accessed atomically.
+type counters struct {
+	emitted [numLevels]uint64
+	dropped [numLevels]uint64
+	bytes   uint64
+	errors  uint64
+	lost    uint64
+	name    string
+}
+
+// wrote records the result of a write to the output or the fallback,
+// or of a call to the Handler.
+func (c *counters) wrote(n int, err error) {
+	if n > 0 {
+		atomic.AddUint64(&c.bytes, uint64(n))
+	}
+	if err != nil {
+		atomic.AddUint64(&c.errors, 1)
+	}
+}
+
+// emit records a record written or handled at the given level.
+func (c *counters) emit(level int) {
+	atomic.AddUint64(&c.emitted[levelIndex(level)], 1)
+}
+
+// lose records a record that could not be written nor handled.
+func (c *counters) lose() {
+	atomic.AddUint64(&c.lost, 1)
+}
+
+// LoggerStats are the statistics of the loggers with the same name that share
+// their output.
+type LoggerStats struct {
+	Name    string         // name given by Named, or "" for loggers without a name
+	Emitted map[int]uint64 // records written or handled by level, 0 for Print and Panic
+	Dropped map[int]uint64 // records ignored because their level is disabled
+	Bytes   uint64         // bytes written to the output
+	Errors  uint64         // failed writes and Handle calls
+	Lost    uint64         // records neither written nor handled, even by the fallback
+}
+
+type byName []LoggerStats
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// Named returns a Logger that shares the output, fields and levels of l,
+// and whose records are counted under the given name in Stats. The name
+// is appended to the name of l, if any, after a dot. It is not logged.
+func (l *Logger) Named(name string) *Logger {
+	if l.counters.name != "" {
+		name = l.counters.name + "." + name
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.named[name]
+	if !ok {
+		c = &counters{name: name}
+		l.named[name] = c
+	}
+	return &Logger{core: l.core, fields: l.fields, counters: c}
+}
+
+// Stats returns a snapshot of the statistics of every name used by the
+// loggers sharing the output of l, sorted by name.
+func (l *Logger) Stats() []LoggerStats {
+	l.mu.Lock()
+	named := make([]*counters, 0, len(l.named))
+	for _, c := range l.named {
+		named = append(named, c)
+	}
+	l.mu.Unlock()
+	stats := make([]LoggerStats, len(named))
+	for i, c := range named {
+		s := LoggerStats{
+			Name:    c.name,
+			Emitted: make(map[int]uint64, numLevels),
+			Dropped: make(map[int]uint64, numLevels),
+			Bytes:   atomic.LoadUint64(&c.bytes),
+			Errors:  atomic.LoadUint64(&c.errors),
+			Lost:    atomic.LoadUint64(&c.lost),
+		}
+		for j := 0; j < numLevels; j++ {
+			level := 0
+			if j > 0 {
+				level = 1 << uint(j-1)
+			}
+			s.Emitted[level] = atomic.LoadUint64(&c.emitted[j])
+			s.Dropped[level] = atomic.LoadUint64(&c.dropped[j])
+		}
+		stats[i] = s
+	}
+	sort.Sort(byName(stats))
+	return stats
+}
+
+// WriteMetrics writes the statistics of l to w in the Prometheus text
+// exposition format, as the counters log_records_total and
+// log_dropped_records_total labeled by logger and level, and
+// log_written_bytes_total, log_write_errors_total and
+// log_lost_records_total labeled by logger.
+// Records without a level have the level "none".
+func (l *Logger) WriteMetrics(w io.Writer) error {
+	stats := l.Stats()
+	bw := bufio.NewWriter(w)
+	writeLevelMetric(bw, "log_records_total", "Records written or handled.", stats, func(s LoggerStats) map[int]uint64 { return s.Emitted })
+	writeLevelMetric(bw, "log_dropped_records_total", "Records ignored because their level is disabled.", stats, func(s LoggerStats) map[int]uint64 { return s.Dropped })
+	writeMetric(bw, "log_written_bytes_total", "Bytes written to the output.", stats, func(s LoggerStats) uint64 { return s.Bytes })
+	writeMetric(bw, "log_write_errors_total", "Failed writes and Handle calls.", stats, func(s LoggerStats) uint64 { return s.Errors })
+	writeMetric(bw, "log_lost_records_total", "Records neither written nor handled.", stats, func(s LoggerStats) uint64 { return s.Lost })
+	return bw.Flush()
+}
+
+func writeMetricHeader(w *bufio.Writer, name, help string) {
+	w.WriteString("# HELP " + name + " " + help + "\n")
+	w.WriteString("# TYPE " + name + " counter\n")
+}
+
+func writeLevelMetric(w *bufio.Writer, name, help string, stats []LoggerStats, values func(LoggerStats) map[int]uint64) {
+	writeMetricHeader(w, name, help)
+	for _, s := range stats {
+		m := values(s)
+		for j := 0; j < numLevels; j++ {
+			level, levelName := 0, "none"
+			if j > 0 {
+				level = 1 << uint(j-1)
+				levelName = LevelName(level)
+			}
+			w.WriteString(name + `{logger="` + escapeLabel(s.Name) + `",level="` + levelName + `"} `)
+			w.WriteString(strconv.FormatUint(m[level], 10) + "\n")
+		}
+	}
+}
+
+func writeMetric(w *bufio.Writer, name, help string, stats []LoggerStats, value func(LoggerStats) uint64) {
+	writeMetricHeader(w, name, help)
+	for _, s := range stats {
+		w.WriteString(name + `{logger="` + escapeLabel(s.Name) + `"} `)
+		w.WriteString(strconv.FormatUint(value(s), 10) + "\n")
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value of the text exposition format.
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,101 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// failWriter fails every write.
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestStats(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b, 0, LevelAll&^LevelDebug)
+	db := l.Named("db")
+	db.With("table", "users").Info("query")
+	db.Named("pool").Error("timeout")
+	l.Debug("ignored")
+	l.V(LevelDebug).Printf("ignored %d", 2)
+	l.Print("hello")
+	if !l.Enabled(LevelInfo) || l.Enabled(LevelDebug) || l.V(LevelDebug).Enabled() {
+		t.Fatal("unexpected levels")
+	}
+
+	stats := l.Stats()
+	if len(stats) != 3 || stats[0].Name != "" || stats[1].Name != "db" || stats[2].Name != "db.pool" {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if s := stats[0]; s.Emitted[0] != 1 || s.Dropped[LevelDebug] != 2 || s.Bytes != 6 || s.Errors != 0 {
+		t.Errorf("unexpected root stats %+v", s)
+	}
+	if s := stats[1]; s.Emitted[LevelInfo] != 1 || s.Bytes != uint64(len("INFO query table=users\n")) {
+		t.Errorf("unexpected db stats %+v", s)
+	}
+	if s := stats[2]; s.Emitted[LevelError] != 1 || s.Emitted[LevelInfo] != 0 {
+		t.Errorf("unexpected db.pool stats %+v", s)
+	}
+
+	l.SetOutput(failWriter{})
+	l.SetErrorHandler(func(error, Record) {})
+	db.Warning("lost")
+	if s := l.Stats()[1]; s.Emitted[LevelWarning] != 0 || s.Errors != 1 || s.Lost != 1 {
+		t.Errorf("unexpected db stats after failure %+v", s)
+	}
+}
+
+func TestStatsConcurrent(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b, 0, LevelInfo)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n := l.Named("worker")
+			for j := 0; j < 100; j++ {
+				n.Info("x")
+				n.Debug("x")
+				l.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+	s := l.Stats()[1]
+	if s.Emitted[LevelInfo] != 800 || s.Dropped[LevelDebug] != 800 || s.Bytes != 800*7 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b, 0, LevelInfo)
+	l.Named(`a"b`).Info("x")
+	l.Debug("x")
+	var m bytes.Buffer
+	if err := l.WriteMetrics(&m); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE log_records_total counter\n",
+		`log_records_total{logger="a\"b",level="info"} 1` + "\n",
+		`log_records_total{logger="",level="none"} 0` + "\n",
+		`log_dropped_records_total{logger="",level="debug"} 1` + "\n",
+		`log_written_bytes_total{logger="a\"b"} 7` + "\n",
+		`log_write_errors_total{logger=""} 0` + "\n",
+	} {
+		if !strings.Contains(m.String(), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, m.String())
+		}
+	}
+}
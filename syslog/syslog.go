@@ -0,0 +1,366 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package syslog provides a log.Handler that sends records to a syslog
+// daemon, over a unix socket, UDP or TCP, in the format of RFC 5424 or of
+// RFC 3164.
+//
+//	h, err := syslog.Dial("", "", &syslog.Options{Facility: syslog.Local0})
+//	if err != nil {
+//		// handle error
+//	}
+//	defer h.Close()
+//	logger.SetHandler(h)
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-gem/log"
+)
+
+// A Facility is the facility part of a syslog priority.
+type Facility int
+
+// Facilities, from /usr/include/sys/syslog.h.
+const (
+	Kern Facility = iota << 3
+	User
+	Mail
+	Daemon
+	Auth
+	Syslog
+	Lpr
+	News
+	Uucp
+	Cron
+	Authpriv
+	Ftp
+	_ // unused
+	_ // unused
+	_ // unused
+	_ // unused
+	Local0
+	Local1
+	Local2
+	Local3
+	Local4
+	Local5
+	Local6
+	Local7
+)
+
+// A Severity is the severity part of a syslog priority.
+type Severity int
+
+// Severities, from /usr/include/sys/syslog.h.
+const (
+	Emerg Severity = iota
+	Alert
+	Crit
+	Err
+	Warning
+	Notice
+	Info
+	Debug
+)
+
+// LevelSeverity returns the severity of records at the given level.
+// Records without a level, written by Print and Panic, are Notice.
+func LevelSeverity(level int) Severity {
+	switch level {
+	case log.LevelDebug:
+		return Debug
+	case log.LevelInfo:
+		return Info
+	case log.LevelWarning:
+		return Warning
+	case log.LevelError:
+		return Err
+	case log.LevelFatal:
+		return Crit
+	}
+	return Notice
+}
+
+// A Format is the layout of a syslog message.
+type Format int
+
+// Formats.
+const (
+	RFC5424 Format = iota // <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+	RFC3164               // <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+)
+
+// A Framing is the way messages are delimited on stream connections.
+// Datagram connections always carry one message per datagram.
+type Framing int
+
+// Framings, as described in RFC 6587.
+const (
+	NonTransparent Framing = iota // each message is followed by a newline; its own are escaped as \n
+	OctetCounting                 // each message is preceded by its length and a space
+)
+
+// DefaultStructuredDataID is the SD-ID under which fields are sent in the
+// RFC 5424 format unless Options.StructuredDataID is set. 32473 is the
+// enterprise number reserved for documentation.
+const DefaultStructuredDataID = "fields@32473"
+
+// Options configure a Handler. The zero value sends RFC 5424 messages
+// with the User facility and non-transparent framing.
+type Options struct {
+	Facility         Facility // Kern is reserved for the kernel and means User
+	AppName          string   // defaults to the base name of os.Args[0]
+	Hostname         string   // defaults to os.Hostname
+	Format           Format
+	Framing          Framing
+	StructuredDataID string // defaults to DefaultStructuredDataID
+}
+
+// ErrClosed is returned by Handle after Close.
+var ErrClosed = errors.New("syslog: handler is closed")
+
+// A Handler is a log.Handler that sends records to a syslog daemon.
+// If a write fails, it reconnects and tries once more.
+// A Handler can be used simultaneously from multiple goroutines.
+type Handler struct {
+	mu     sync.Mutex
+	opts   Options
+	pid    int
+	dial   func() (net.Conn, error)
+	conn   net.Conn
+	stream bool // whether conn is a stream connection
+	closed bool
+	buf    []byte
+}
+
+// Dial connects to the syslog daemon at raddr on the given network, which
+// is one of "unix", "unixgram", "udp" or "tcp" (and their 4 and 6
+// variants). If network is empty, Dial connects to the local daemon
+// through the usual unix sockets. opts may be nil.
+func Dial(network, raddr string, opts *Options) (*Handler, error) {
+	h := newHandler(opts)
+	if network == "" {
+		h.dial = dialLocal
+	} else {
+		h.dial = func() (net.Conn, error) {
+			return net.Dial(network, raddr)
+		}
+	}
+	if err := h.connect(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func newHandler(opts *Options) *Handler {
+	h := &Handler{pid: os.Getpid()}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Facility == Kern {
+		h.opts.Facility = User
+	}
+	if h.opts.AppName == "" {
+		h.opts.AppName = filepath.Base(os.Args[0])
+	}
+	if h.opts.Hostname == "" {
+		h.opts.Hostname, _ = os.Hostname()
+	}
+	if h.opts.StructuredDataID == "" {
+		h.opts.StructuredDataID = DefaultStructuredDataID
+	}
+	return h
+}
+
+// dialLocal connects to the local syslog daemon.
+func dialLocal() (net.Conn, error) {
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+			conn, err := net.Dial(network, path)
+			if err == nil {
+				return conn, nil
+			}
+		}
+	}
+	return nil, errors.New("syslog: unix syslog delivery error")
+}
+
+func (h *Handler) connect() error {
+	conn, err := h.dial()
+	if err != nil {
+		return err
+	}
+	h.conn = conn
+	switch conn.RemoteAddr().Network() {
+	case "tcp", "tcp4", "tcp6", "unix":
+		h.stream = true
+	default:
+		h.stream = false
+	}
+	return nil
+}
+
+// Handle implements log.Handler.
+func (h *Handler) Handle(r log.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrClosed
+	}
+	if h.conn != nil {
+		h.buf = h.format(h.buf[:0], &r)
+		if _, err := h.conn.Write(h.buf); err == nil {
+			return nil
+		}
+		h.conn.Close()
+		h.conn = nil
+	}
+	if err := h.connect(); err != nil {
+		return err
+	}
+	// the framing depends on the new connection.
+	h.buf = h.format(h.buf[:0], &r)
+	_, err := h.conn.Write(h.buf)
+	return err
+}
+
+// Close closes the connection to the syslog daemon. Handle then returns
+// ErrClosed rather than reconnecting.
+func (h *Handler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	if h.conn == nil {
+		return nil
+	}
+	err := h.conn.Close()
+	h.conn = nil
+	return err
+}
+
+// format appends the framed message for r to buf.
+func (h *Handler) format(buf []byte, r *log.Record) []byte {
+	var msg []byte
+	pri := int(h.opts.Facility) | int(LevelSeverity(r.Level))
+	t := r.Time
+	if h.opts.Format == RFC3164 {
+		msg = append(msg, '<')
+		msg = strconv.AppendInt(msg, int64(pri), 10)
+		msg = append(msg, '>')
+		msg = t.AppendFormat(msg, time.Stamp)
+		msg = append(msg, ' ')
+		msg = append(msg, nilValue(h.opts.Hostname, 255)...)
+		msg = append(msg, ' ')
+		msg = append(msg, h.opts.AppName...)
+		msg = append(msg, '[')
+		msg = strconv.AppendInt(msg, int64(h.pid), 10)
+		msg = append(msg, "]: "...)
+		msg = append(msg, r.Message...)
+		for _, f := range r.Fields {
+			msg = append(msg, ' ')
+			msg = append(msg, f.Key...)
+			msg = append(msg, '=')
+			msg = strconv.AppendQuote(msg, fmt.Sprint(f.Value))
+		}
+	} else {
+		msg = append(msg, '<')
+		msg = strconv.AppendInt(msg, int64(pri), 10)
+		msg = append(msg, ">1 "...)
+		msg = t.AppendFormat(msg, "2006-01-02T15:04:05.000000Z07:00")
+		msg = append(msg, ' ')
+		msg = append(msg, nilValue(h.opts.Hostname, 255)...)
+		msg = append(msg, ' ')
+		msg = append(msg, nilValue(h.opts.AppName, 48)...)
+		msg = append(msg, ' ')
+		msg = strconv.AppendInt(msg, int64(h.pid), 10)
+		msg = append(msg, " - "...)
+		msg = h.appendStructuredData(msg, r.Fields)
+		if r.Message != "" {
+			msg = append(msg, ' ')
+			msg = append(msg, r.Message...)
+		}
+	}
+	if !h.stream {
+		return append(buf, msg...)
+	}
+	if h.opts.Framing == OctetCounting {
+		buf = strconv.AppendInt(buf, int64(len(msg)), 10)
+		buf = append(buf, ' ')
+		return append(buf, msg...)
+	}
+	// a newline in msg would end the frame early.
+	for _, c := range msg {
+		if c == '\n' {
+			buf = append(buf, '\\', 'n')
+		} else {
+			buf = append(buf, c)
+		}
+	}
+	return append(buf, '\n')
+}
+
+// appendStructuredData appends fields as a single SD-ELEMENT, or the nil
+// value if there are none.
+func (h *Handler) appendStructuredData(buf []byte, fields []log.Field) []byte {
+	if len(fields) == 0 {
+		return append(buf, '-')
+	}
+	buf = append(buf, '[')
+	buf = append(buf, h.opts.StructuredDataID...)
+	for _, f := range fields {
+		buf = append(buf, ' ')
+		buf = append(buf, paramName(f.Key)...)
+		buf = append(buf, `="`...)
+		v := fmt.Sprint(f.Value)
+		for i := 0; i < len(v); i++ {
+			switch c := v[i]; c {
+			case '"', '\\', ']':
+				buf = append(buf, '\\', c)
+			default:
+				buf = append(buf, c)
+			}
+		}
+		buf = append(buf, '"')
+	}
+	return append(buf, ']')
+}
+
+// paramName returns key with the characters that are not allowed in an
+// SD-NAME replaced by '_', truncated to 32 characters.
+func paramName(key string) string {
+	if key == "" {
+		return "_"
+	}
+	name := []byte(key)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	for i, c := range name {
+		if c <= ' ' || c >= 0x7f || c == '=' || c == ']' || c == '"' {
+			name[i] = '_'
+		}
+	}
+	return string(name)
+}
+
+// nilValue returns s, truncated to max bytes, or the nil value "-" if s
+// is empty.
+func nilValue(s string, max int) string {
+	if s == "" {
+		return "-"
+	}
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
@@ -0,0 +1,240 @@
+// Copyright 2016 The Gem Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package syslog
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gem/log"
+)
+
+var testTime = time.Date(2009, 11, 10, 23, 0, 0, 123456000, time.UTC)
+
+func testRecord() log.Record {
+	return log.Record{
+		Time:    testTime,
+		Level:   log.LevelError,
+		Message: "disk full",
+		Fields:  []log.Field{{Key: "path", Value: `/var/"x"]`}, {Key: "bad key", Value: 1}},
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		{
+			Options{Facility: Local0, AppName: "app", Hostname: "host"},
+			`<131>1 2009-11-10T23:00:00.123456Z host app 42 - [fields@32473 path="/var/\"x\"\]" bad_key="1"] disk full`,
+		},
+		{
+			Options{Facility: Daemon, AppName: "app", Hostname: "host", StructuredDataID: "my@1", Format: RFC5424},
+			`<27>1 2009-11-10T23:00:00.123456Z host app 42 - [my@1 path="/var/\"x\"\]" bad_key="1"] disk full`,
+		},
+		{
+			Options{Facility: Local7, AppName: "app", Hostname: "host", Format: RFC3164},
+			`<187>Nov 10 23:00:00 host app[42]: disk full path="/var/\"x\"]" bad key="1"`,
+		},
+	}
+	for _, test := range tests {
+		h := newHandler(&test.opts)
+		h.pid = 42
+		r := testRecord()
+		if got := string(h.format(nil, &r)); got != test.want {
+			t.Errorf("got  %s\nwant %s", got, test.want)
+		}
+	}
+}
+
+func TestLevelSeverity(t *testing.T) {
+	levels := map[int]Severity{
+		0:                Notice,
+		log.LevelDebug:   Debug,
+		log.LevelInfo:    Info,
+		log.LevelWarning: Warning,
+		log.LevelError:   Err,
+		log.LevelFatal:   Crit,
+	}
+	for level, severity := range levels {
+		if got := LevelSeverity(level); got != severity {
+			t.Errorf("LevelSeverity(%d) = %d; want %d", level, got, severity)
+		}
+	}
+}
+
+func TestUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer pc.Close()
+	h, err := Dial("udp", pc.LocalAddr().String(), &Options{AppName: "app", Hostname: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	l := log.New(nil, 0, log.LevelAll)
+	l.SetHandler(h)
+	l.Warning("hello")
+
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 1024)
+	n, _, err := pc.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b[:n])
+	suffix := " host app " + strconv.Itoa(os.Getpid()) + " - - hello"
+	if !strings.HasPrefix(got, "<12>1 ") || !strings.HasSuffix(got, suffix) {
+		t.Errorf("unexpected message %q", got)
+	}
+}
+
+func TestTCPOctetCounting(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	h, err := Dial("tcp", ln.Addr().String(), &Options{Framing: OctetCounting, AppName: "app", Hostname: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	h.pid = 42
+	for i := 0; i < 2; i++ {
+		if err := h.Handle(testRecord()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := bufio.NewReader(conn)
+	want := `<11>1 2009-11-10T23:00:00.123456Z host app 42 - [fields@32473 path="/var/\"x\"\]" bad_key="1"] disk full`
+	for i := 0; i < 2; i++ {
+		length, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(length[:len(length)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatal(err)
+		}
+		if string(msg) != want {
+			t.Errorf("got  %s\nwant %s", msg, want)
+		}
+	}
+}
+
+func TestUnixgram(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	addr := &net.UnixAddr{Name: filepath.Join(dir, "log"), Net: "unixgram"}
+	pc, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer pc.Close()
+	h, err := Dial("unixgram", addr.Name, &Options{Format: RFC3164, AppName: "app", Hostname: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	h.pid = 42
+	if err := h.Handle(log.Record{Time: testTime, Level: log.LevelInfo, Message: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := pc.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<14>Nov 10 23:00:00 host app[42]: hello"; string(b[:n]) != want {
+		t.Errorf("got %q; want %q", b[:n], want)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	servers := make(chan net.Conn, 2)
+	h := newHandler(&Options{AppName: "app", Hostname: "host"})
+	h.dial = func() (net.Conn, error) {
+		client, server := net.Pipe()
+		servers <- server
+		return client, nil
+	}
+	if err := h.connect(); err != nil {
+		t.Fatal(err)
+	}
+	(<-servers).Close()
+
+	done := make(chan string)
+	go func() {
+		b := make([]byte, 1024)
+		n, _ := (<-servers).Read(b)
+		done <- string(b[:n])
+	}()
+	if err := h.Handle(log.Record{Time: testTime, Message: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-done; !strings.HasSuffix(got, " - - hello") {
+		t.Errorf("unexpected message %q on the new connection", got)
+	}
+}
+
+func TestNonTransparentNewline(t *testing.T) {
+	h := newHandler(&Options{AppName: "app", Hostname: "host"})
+	h.pid = 42
+	h.stream = true
+	r := log.Record{Time: testTime, Message: "one\n<11>1 forged"}
+	want := "<13>1 2009-11-10T23:00:00.123456Z host app 42 - - one\\n<11>1 forged\n"
+	if got := string(h.format(nil, &r)); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestHandleAfterClose(t *testing.T) {
+	dials := 0
+	h := newHandler(nil)
+	h.dial = func() (net.Conn, error) {
+		dials++
+		client, server := net.Pipe()
+		go io.Copy(ioutil.Discard, server)
+		return client, nil
+	}
+	if err := h.connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Handle(testRecord()); err != ErrClosed {
+		t.Errorf("Handle after Close = %v, want ErrClosed", err)
+	}
+	if dials != 1 {
+		t.Errorf("dialed %d times, want 1", dials)
+	}
+}